@@ -0,0 +1,328 @@
+package mse6
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const authRealm = "mse6"
+const authUser = "mse6"
+const authPass = "mse6"
+const authToken = "mse6"
+const digestNonceSeconds = 300
+const digestOpaque = "bXNlNg"
+
+// digestSecret signs digest nonces so they can be validated without keeping state.
+var digestSecret = func() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}()
+
+func authCredentials(r *http.Request) (string, string) {
+	user, pass := authUser, authPass
+	if len(r.URL.Query().Get("user")) > 0 {
+		user = r.URL.Query().Get("user")
+	}
+	if len(r.URL.Query().Get("pass")) > 0 {
+		pass = r.URL.Query().Get("pass")
+	}
+	return user, pass
+}
+
+func sendAuthorized(w http.ResponseWriter, r *http.Request, scheme string, user string) {
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.WriteHeader(200)
+	w.Write([]byte(fmt.Sprintf(`{"mse6":"Hello from the %s endpoint", "authenticated":"%s"}`, strings.TrimPrefix(r.URL.Path, Prefix), user)))
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 %s authenticated user %s", r.URL.Path, getXRequestId(r), scheme, user)
+}
+
+func sendChallenge(w http.ResponseWriter, r *http.Request, challenges ...string) {
+	for _, c := range challenges {
+		w.Header().Add("WWW-Authenticate", c)
+	}
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.WriteHeader(401)
+	w.Write([]byte(`{"mse6":"401"}`))
+	log.Info().Msgf("served %v request with X-Request-Id %s code 401 challenge %s", r.URL.Path, getXRequestId(r), strings.Join(challenges, " | "))
+}
+
+func basicChallenge() string {
+	return fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, authRealm)
+}
+
+func basicauth(w http.ResponseWriter, r *http.Request) {
+	user, pass := authCredentials(r)
+	u, p, ok := r.BasicAuth()
+	if ok && u == user && p == pass {
+		sendAuthorized(w, r, "Basic", u)
+		return
+	}
+	sendChallenge(w, r, basicChallenge())
+}
+
+// bearerChallenge builds a RFC 6750 challenge. errCode is empty when the request carried no token.
+func bearerChallenge(errCode string) string {
+	switch errCode {
+	case "":
+		return fmt.Sprintf(`Bearer realm="%s"`, authRealm)
+	case "insufficient_scope":
+		return fmt.Sprintf(`Bearer realm="%s", error="insufficient_scope", error_description="the request requires higher privileges", scope="mse6"`, authRealm)
+	case "expired":
+		return fmt.Sprintf(`Bearer realm="%s", error="invalid_token", error_description="the access token expired"`, authRealm)
+	default:
+		return fmt.Sprintf(`Bearer realm="%s", error="invalid_token", error_description="the access token is invalid"`, authRealm)
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	a := r.Header.Get("Authorization")
+	if len(a) > 7 && strings.EqualFold(a[:7], "Bearer ") {
+		return strings.TrimSpace(a[7:]), true
+	}
+	return "", false
+}
+
+// bearerauth accepts the configured token. The error parameter rejects every token with
+// invalid_token, expired or insufficient_scope.
+func bearerauth(w http.ResponseWriter, r *http.Request) {
+	token := authToken
+	if len(r.URL.Query().Get("token")) > 0 {
+		token = r.URL.Query().Get("token")
+	}
+	t, ok := bearerToken(r)
+	if !ok {
+		sendChallenge(w, r, bearerChallenge(""))
+		return
+	}
+	if e := r.URL.Query().Get("error"); len(e) > 0 {
+		if e == "insufficient_scope" {
+			w.Header().Set("WWW-Authenticate", bearerChallenge(e))
+			w.Header().Set("Server", "mse6 "+Version)
+			w.Header().Set("Content-Encoding", "identity")
+			w.WriteHeader(403)
+			w.Write([]byte(`{"mse6":"403"}`))
+			log.Info().Msgf("served %v request with X-Request-Id %s code 403 insufficient_scope", r.URL.Path, getXRequestId(r))
+			return
+		}
+		sendChallenge(w, r, bearerChallenge(e))
+		return
+	}
+	if t != token {
+		sendChallenge(w, r, bearerChallenge("invalid_token"))
+		return
+	}
+	sendAuthorized(w, r, "Bearer", "token")
+}
+
+func digestHash(algorithm string) hash.Hash {
+	if strings.HasPrefix(strings.ToUpper(algorithm), "SHA-256") {
+		return sha256.New()
+	}
+	return md5.New()
+}
+
+func digestH(algorithm string, s string) string {
+	h := digestHash(algorithm)
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newDigestNonce issues a nonce that encodes its issue time and whether it was issued by a stale challenge.
+func newDigestNonce(renewed bool) string {
+	payload := fmt.Sprintf("%d:%t", time.Now().Unix(), renewed)
+	mac := hmac.New(sha256.New, digestSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload + ":" + hex.EncodeToString(mac.Sum(nil))))
+}
+
+// checkDigestNonce returns whether the nonce was issued by this server, whether it is expired,
+// and whether it was issued by a stale challenge.
+func checkDigestNonce(nonce string) (bool, bool, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil {
+		return false, false, false
+	}
+	parts := strings.Split(string(b), ":")
+	if len(parts) != 3 {
+		return false, false, false
+	}
+	mac := hmac.New(sha256.New, digestSecret)
+	mac.Write([]byte(parts[0] + ":" + parts[1]))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(parts[2])) {
+		return false, false, false
+	}
+	issued, _ := strconv.ParseInt(parts[0], 10, 64)
+	expired := time.Now().Unix()-issued > digestNonceSeconds
+	return true, expired, parts[1] == "true"
+}
+
+func digestChallenge(algorithm string, stale bool, renewed bool) string {
+	c := fmt.Sprintf(`Digest realm="%s", qop="auth", algorithm=%s, nonce="%s", opaque="%s"`, authRealm, algorithm, newDigestNonce(renewed), digestOpaque)
+	if stale {
+		c += ", stale=true"
+	}
+	return c
+}
+
+// parseAuthParams parses the comma separated auth-param list of an Authorization header, honouring quoted strings.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		k := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+		v := ""
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(s) {
+				end = len(s)
+			}
+			v = strings.Replace(s[1:end], `\"`, `"`, -1)
+			if end < len(s) {
+				end++
+			}
+			s = s[end:]
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				end = len(s)
+			}
+			v = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[k] = v
+	}
+	return params
+}
+
+// digestauth implements RFC 7616 Digest with qop auth and MD5 or SHA-256. With stale=true the first
+// correct response to each nonce is rejected as stale, so clients must retry with the fresh nonce.
+func digestauth(w http.ResponseWriter, r *http.Request) {
+	algorithm := "MD5"
+	if strings.ToUpper(r.URL.Query().Get("algorithm")) == "SHA-256" {
+		algorithm = "SHA-256"
+	}
+	forceStale := r.URL.Query().Get("stale") == "true"
+
+	a := r.Header.Get("Authorization")
+	if len(a) < 7 || !strings.EqualFold(a[:7], "Digest ") {
+		sendChallenge(w, r, digestChallenge(algorithm, false, false))
+		return
+	}
+
+	p := parseAuthParams(a[7:])
+	if !digestURIMatches(r, p["uri"]) {
+		sendDigestURIMismatch(w, r, p["uri"])
+		return
+	}
+	ok, stale, renewed := digestVerify(r, p, algorithm)
+	if ok && forceStale && !renewed {
+		stale = true
+	}
+	if ok && !stale {
+		sendAuthorized(w, r, "Digest", p["username"])
+		return
+	}
+	sendChallenge(w, r, digestChallenge(algorithm, stale, stale))
+}
+
+// digestURIMatches implements the RFC 7616 section 3.4.6 check that the uri parameter is the request target,
+// so a response captured for one URI can't authenticate another.
+func digestURIMatches(r *http.Request, uri string) bool {
+	return uri == r.RequestURI || uri == r.URL.RequestURI()
+}
+
+func sendDigestURIMismatch(w http.ResponseWriter, r *http.Request, uri string) {
+	sendJSON(w, 400, map[string]string{"mse6": "400", "error": "digest uri doesn't match the request target"})
+	log.Info().Msgf("served %v request with X-Request-Id %s code 400 digest uri %s doesn't match request target %s", r.URL.Path, getXRequestId(r), uri, r.RequestURI)
+}
+
+// digestVerify returns whether the digest response is correct, whether the nonce is stale and whether
+// the nonce was renewed by a stale challenge.
+func digestVerify(r *http.Request, p map[string]string, algorithm string) (bool, bool, bool) {
+	user, pass := authCredentials(r)
+	valid, expired, renewed := checkDigestNonce(p["nonce"])
+	if !valid || p["username"] != user || p["realm"] != authRealm || p["qop"] != "auth" {
+		return false, false, false
+	}
+	if len(p["algorithm"]) > 0 && !strings.EqualFold(p["algorithm"], algorithm) {
+		return false, false, false
+	}
+	ha1 := digestH(algorithm, user+":"+authRealm+":"+pass)
+	ha2 := digestH(algorithm, r.Method+":"+p["uri"])
+	want := digestH(algorithm, strings.Join([]string{ha1, p["nonce"], p["nc"], p["cnonce"], p["qop"], ha2}, ":"))
+	if !hmac.Equal([]byte(want), []byte(strings.ToLower(p["response"]))) {
+		return false, false, false
+	}
+	return true, expired, renewed
+}
+
+// multichallenge sends Digest, Basic and Bearer challenges in a single WWW-Authenticate header
+// and accepts valid credentials for any of them.
+func multichallenge(w http.ResponseWriter, r *http.Request) {
+	user, pass := authCredentials(r)
+	if u, p, ok := r.BasicAuth(); ok && u == user && p == pass {
+		sendAuthorized(w, r, "Basic", u)
+		return
+	}
+	if t, ok := bearerToken(r); ok && t == authToken {
+		sendAuthorized(w, r, "Bearer", "token")
+		return
+	}
+	if a := r.Header.Get("Authorization"); len(a) > 7 && strings.EqualFold(a[:7], "Digest ") {
+		p := parseAuthParams(a[7:])
+		if !digestURIMatches(r, p["uri"]) {
+			sendDigestURIMismatch(w, r, p["uri"])
+			return
+		}
+		if ok, stale, _ := digestVerify(r, p, "MD5"); ok && !stale {
+			sendAuthorized(w, r, "Digest", p["username"])
+			return
+		}
+	}
+	sendChallenge(w, r, strings.Join([]string{digestChallenge("MD5", false, false), basicChallenge(), bearerChallenge("")}, ", "))
+}
+
+var malformedChallenges = []string{
+	`Basic realm=mse6"`,
+	`Basic`,
+	`Digest realm="mse6", qop="auth", nonce=`,
+	`Digest realm="mse6, nonce="abc", qop=auth`,
+	`Bearer realm="mse6" error="invalid_token"`,
+	`Basic realm="mse6",, ,Bearer`,
+	`=realm="mse6"`,
+	`Negotiate YII=====`,
+}
+
+// badchallenge sends a malformed WWW-Authenticate challenge, selected by n.
+func badchallenge(w http.ResponseWriter, r *http.Request) {
+	n := parseQueryInt(r, "n", 0)
+	if n < 0 || n >= len(malformedChallenges) {
+		n = 0
+	}
+	sendChallenge(w, r, malformedChallenges[n])
+}
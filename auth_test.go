@@ -0,0 +1,185 @@
+package mse6
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBasicAuthResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(basicauth))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		user string
+		pass string
+		code int
+	}{
+		{"no credentials", "", "", 401},
+		{"wrong credentials", "mse6", "wrong", 401},
+		{"credentials", "mse6", "mse6", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL, nil)
+			if len(tt.user) > 0 {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if tt.code == 401 && !strings.HasPrefix(res.Header.Get("WWW-Authenticate"), "Basic ") {
+				t.Errorf("want Basic challenge, got %v", res.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestBearerAuthResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(bearerauth))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		query     string
+		token     string
+		code      int
+		challenge string
+	}{
+		{"no token", "", "", 401, `Bearer realm="mse6"`},
+		{"wrong token", "", "wrong", 401, `error="invalid_token"`},
+		{"token", "", "mse6", 200, ""},
+		{"custom token", "?token=abc", "abc", 200, ""},
+		{"insufficient scope", "?error=insufficient_scope", "mse6", 403, `error="insufficient_scope"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+tt.query, nil)
+			if len(tt.token) > 0 {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if !strings.Contains(res.Header.Get("WWW-Authenticate"), tt.challenge) {
+				t.Errorf("want challenge containing %v, got %v", tt.challenge, res.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func digestAuthorization(challenge string, method string, uri string, user string, pass string) string {
+	p := parseAuthParams(strings.TrimPrefix(challenge, "Digest "))
+	alg := p["algorithm"]
+	ha1 := digestH(alg, user+":"+p["realm"]+":"+pass)
+	ha2 := digestH(alg, method+":"+uri)
+	nc, cnonce := "00000001", "0a4f113b"
+	resp := digestH(alg, strings.Join([]string{ha1, p["nonce"], nc, cnonce, "auth", ha2}, ":"))
+	return fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, qop=auth, nc=%s, cnonce="%s", response="%s", opaque="%s"`,
+		user, p["realm"], p["nonce"], uri, alg, nc, cnonce, resp, p["opaque"])
+}
+
+func TestDigestAuthResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(digestauth))
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		pass  string
+		codes []int
+	}{
+		{"md5", "?algorithm=MD5", "mse6", []int{401, 200}},
+		{"sha256", "?algorithm=SHA-256", "mse6", []int{401, 200}},
+		{"wrong password", "", "wrong", []int{401, 401}},
+		{"stale", "?stale=true", "mse6", []int{401, 401, 200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := ""
+			for i, code := range tt.codes {
+				req, _ := http.NewRequest("GET", srv.URL+"/digestauth"+tt.query, nil)
+				if len(auth) > 0 {
+					req.Header.Set("Authorization", auth)
+				}
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Errorf("server did not return ok cause %v", err)
+					return
+				}
+				res.Body.Close()
+				if res.StatusCode != code {
+					t.Errorf("attempt %d response status code want %v, got %v", i, code, res.StatusCode)
+				}
+				if tt.name == "stale" && i == 1 && !strings.Contains(res.Header.Get("WWW-Authenticate"), "stale=true") {
+					t.Errorf("want stale challenge, got %v", res.Header.Get("WWW-Authenticate"))
+				}
+				auth = digestAuthorization(res.Header.Get("WWW-Authenticate"), "GET", "/digestauth"+tt.query, "mse6", tt.pass)
+			}
+		})
+	}
+}
+
+func TestDigestAuthRejectsUriMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(digestauth))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/digestauth")
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/digestauth", nil)
+	req.Header.Set("Authorization", digestAuthorization(res.Header.Get("WWW-Authenticate"), "GET", "/other", "mse6", "mse6"))
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("response status code want 400, got %v", res.StatusCode)
+	}
+}
+
+func TestMultiChallengeResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(multichallenge))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+
+	c := res.Header.Get("WWW-Authenticate")
+	if len(res.Header["Www-Authenticate"]) != 1 {
+		t.Errorf("want exactly one WWW-Authenticate header, got %v", len(res.Header["Www-Authenticate"]))
+	}
+	for _, s := range []string{"Digest ", "Basic ", "Bearer "} {
+		if !strings.Contains(c, s) {
+			t.Errorf("want %s challenge in %v", s, c)
+		}
+	}
+}
@@ -3,7 +3,7 @@ package mse6
 import (
 	"bytes"
 	"github.com/andybalholm/brotli"
-	"io/ioutil"
+	"io"
 	"sync"
 )
 
@@ -11,44 +11,48 @@ const brotliLevel int = 1
 
 var brotliEmpty = []byte{0}
 
-var brotliEncPool = sync.Pool{
-	New: func() interface{} {
-		var buf bytes.Buffer
-		return brotli.NewWriterLevel(&buf, brotliLevel)
-	},
+type brotliCodec struct {
+	encPool sync.Pool
+	decPool sync.Pool
 }
 
-var brotliDecPool = sync.Pool{
-	New: func() interface{} {
-		buf := bytes.NewBuffer(brotliEmpty)
-		r := brotli.NewReader(buf)
-		return r
-	},
+func newBrotliCodec() *brotliCodec {
+	return &brotliCodec{
+		encPool: sync.Pool{
+			New: func() interface{} {
+				return brotli.NewWriterLevel(nil, brotliLevel)
+			},
+		},
+		decPool: sync.Pool{
+			New: func() interface{} {
+				return brotli.NewReader(bytes.NewBuffer(brotliEmpty))
+			},
+		},
+	}
 }
 
-// BrotliEncode encodes to brotli from byte array.
-func BrotliEncode(input []byte) *[]byte {
-	wrt, _ := brotliEncPool.Get().(*brotli.Writer)
-	buf := &bytes.Buffer{}
-	wrt.Reset(buf)
-
-	_, _ = wrt.Write(input)
-	_ = wrt.Close()
-	defer brotliEncPool.Put(wrt)
+func (c *brotliCodec) Name() string {
+	return "br"
+}
 
-	enc := buf.Bytes()
-	return &enc
+func (c *brotliCodec) Encode(w io.Writer) (io.WriteCloser, error) {
+	return newPooledWriter(&c.encPool, w), nil
 }
 
-// BrotliDecode decodes a []byte from Brotli binary format
-func BrotliDecode(input []byte) *[]byte {
-	rd, _ := brotliDecPool.Get().(*brotli.Reader)
-	buf := bytes.NewBuffer(input)
-	_ = rd.Reset(buf)
+func (c *brotliCodec) Decode(r io.Reader) (io.ReadCloser, error) {
+	rd := c.decPool.Get().(*brotli.Reader)
+	if err := rd.Reset(r); err != nil {
+		return nil, err
+	}
+	return &pooledReader{dec: rd, item: rd, pool: &c.decPool}, nil
+}
 
-	dec, _ := ioutil.ReadAll(rd)
-	_ = rd.Reset(buf)
-	defer brotliDecPool.Put(rd)
+// BrotliEncode encodes to brotli from byte array.
+func BrotliEncode(input []byte) ([]byte, error) {
+	return encodeBytes("br", input)
+}
 
-	return &dec
+// BrotliDecode decodes a []byte from Brotli binary format
+func BrotliDecode(input []byte) ([]byte, error) {
+	return decodeBytes("br", input)
 }
@@ -4,9 +4,10 @@ import "testing"
 
 func TestBrotliEncodeAndDecode(t *testing.T) {
 	want := "ResistanceIsFutile"
-	got := BrotliDecode(*BrotliEncode([]byte(want)))
+	enc, _ := BrotliEncode([]byte(want))
+	got, err := BrotliDecode(enc)
 
-	if string(*got) != want {
-		t.Errorf("brotli decode/encode failed. want %v got %v", want, got)
+	if err != nil || string(got) != want {
+		t.Errorf("brotli decode/encode failed. want %v got %v cause %v", want, string(got), err)
 	}
 }
@@ -0,0 +1,257 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const rangeAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+const rangeBoundary = "mse6byteranges"
+
+// rangeMaxSize caps generated content at 16GiB.
+const rangeMaxSize int64 = 16 << 30
+
+// RangeDir is the directory of files the range route serves with file=name. Files are not served if it's empty.
+var RangeDir string
+
+// rangeLastModified is fixed so that If-Range dates stay valid across restarts.
+var rangeLastModified = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type byteRange struct {
+	first int64
+	last  int64
+}
+
+func (br byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.first, br.last, size)
+}
+
+// rangeContent generates size bytes of content where every byte is determined by its offset, so ranges are
+// generated as they're read, without holding the content in memory.
+type rangeContent int64
+
+func (c rangeContent) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(c) {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if rem := int64(c) - off; int64(n) > rem {
+		n = int(rem)
+	}
+	for i := 0; i < n; i++ {
+		p[i] = rangeAlphabet[(off+int64(i))%int64(len(rangeAlphabet))]
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// rangeResource is the content served by the range route, either generated or read from a file in RangeDir.
+type rangeResource struct {
+	content      io.ReaderAt
+	size         int64
+	etag         string
+	lastModified time.Time
+}
+
+// openRangeFile opens a regular file directly inside RangeDir. Names with a path are rejected so that nothing
+// outside of RangeDir can be read.
+func openRangeFile(name string) (*os.File, rangeResource, error) {
+	if len(RangeDir) == 0 {
+		return nil, rangeResource{}, fmt.Errorf("no range directory configured")
+	}
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return nil, rangeResource{}, fmt.Errorf("invalid file name %s", name)
+	}
+	f, err := os.Open(filepath.Join(RangeDir, name))
+	if err != nil {
+		return nil, rangeResource{}, err
+	}
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, rangeResource{}, fmt.Errorf("%s is not a regular file", name)
+	}
+	return f, rangeResource{
+		content:      f,
+		size:         fi.Size(),
+		etag:         fmt.Sprintf(`"mse6-file-%d-%d"`, fi.Size(), fi.ModTime().UnixNano()),
+		lastModified: fi.ModTime().UTC().Truncate(time.Second),
+	}, nil
+}
+
+func writeRange(w io.Writer, rr rangeResource, br byteRange) {
+	io.Copy(w, io.NewSectionReader(rr.content, br.first, br.last-br.first+1))
+}
+
+// parseRange parses a RFC 7233 bytes range header against size. It returns false if the header is not
+// a valid bytes range and therefore ignored. Unsatisfiable ranges are dropped.
+func parseRange(h string, size int64) ([]byteRange, bool) {
+	if !strings.HasPrefix(h, "bytes=") {
+		return nil, false
+	}
+	ranges := make([]byteRange, 0)
+	for _, spec := range strings.Split(strings.TrimPrefix(h, "bytes="), ",") {
+		spec = strings.TrimSpace(spec)
+		dash := strings.Index(spec, "-")
+		if dash < 0 {
+			return nil, false
+		}
+		fs, ls := spec[:dash], spec[dash+1:]
+		if len(fs) == 0 {
+			n, err := strconv.ParseInt(ls, 10, 64)
+			if err != nil || n < 0 {
+				return nil, false
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			ranges = append(ranges, byteRange{size - n, size - 1})
+			continue
+		}
+		first, err := strconv.ParseInt(fs, 10, 64)
+		if err != nil || first < 0 {
+			return nil, false
+		}
+		last := size - 1
+		if len(ls) > 0 {
+			last, err = strconv.ParseInt(ls, 10, 64)
+			if err != nil || last < first {
+				return nil, false
+			}
+			if last >= size {
+				last = size - 1
+			}
+		}
+		if first >= size {
+			continue
+		}
+		ranges = append(ranges, byteRange{first, last})
+	}
+	return ranges, true
+}
+
+// ifRangeMatches evaluates If-Range against the strong ETag or the Last-Modified date.
+func ifRangeMatches(r *http.Request, etag string, lastModified time.Time) bool {
+	ir := r.Header.Get("If-Range")
+	if len(ir) == 0 {
+		return true
+	}
+	if strings.HasPrefix(ir, `"`) {
+		return ir == etag
+	}
+	t, err := http.ParseTime(ir)
+	return err == nil && t.Equal(lastModified)
+}
+
+// byterange serves size bytes of generated content, or a file in RangeDir with file=name, with RFC 7233 range
+// support. fault=full ignores Range with 200, fault=contentrange sends a wrong Content-Range and fault=overlap adds
+// an overlapping part.
+func byterange(w http.ResponseWriter, r *http.Request) {
+	var rr rangeResource
+	if name := r.URL.Query().Get("file"); len(name) > 0 {
+		f, res, err := openRangeFile(name)
+		if err != nil {
+			send404(w, r)
+			log.Info().Msgf("unable to serve range file %s for X-Request-Id %s, cause: %v", name, getXRequestId(r), err)
+			return
+		}
+		defer f.Close()
+		rr = res
+	} else {
+		size := int64(parseQueryInt(r, "size", 1024))
+		if size < 0 {
+			size = 0
+		}
+		if size > rangeMaxSize {
+			sendJSON(w, 400, map[string]interface{}{"mse6": "400", "error": "size exceeds limit", "max": rangeMaxSize})
+			log.Info().Msgf("served %v request with X-Request-Id %s code 400 size %d exceeds %d", r.URL.Path, getXRequestId(r), size, rangeMaxSize)
+			return
+		}
+		rr = rangeResource{content: rangeContent(size), size: size, etag: fmt.Sprintf(`"mse6-%d"`, size), lastModified: rangeLastModified}
+	}
+	size, etag := rr.size, rr.etag
+	fault := r.URL.Query().Get("fault")
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", rr.lastModified.Format(http.TimeFormat))
+
+	ranges, ok := parseRange(r.Header.Get("Range"), size)
+	if !ok || fault == "full" || !ifRangeMatches(r, etag, rr.lastModified) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.WriteHeader(200)
+		if r.Method != "HEAD" {
+			writeRange(w, rr, byteRange{0, size - 1})
+		}
+		log.Info().Msgf("served %v request with X-Request-Id %s code 200 full content of %d bytes", r.URL.Path, getXRequestId(r), size)
+		return
+	}
+
+	if len(ranges) == 0 {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		w.WriteHeader(416)
+		log.Info().Msgf("served %v request with X-Request-Id %s code 416 for unsatisfiable range %s", r.URL.Path, getXRequestId(r), r.Header.Get("Range"))
+		return
+	}
+
+	if fault == "overlap" {
+		ranges = append(ranges, byteRange{ranges[0].first, ranges[len(ranges)-1].last})
+		if ranges[0].first > ranges[len(ranges)-1].last {
+			ranges[len(ranges)-1] = byteRange{0, size - 1}
+		}
+	}
+
+	if len(ranges) == 1 {
+		br := ranges[0]
+		cr := br.contentRange(size)
+		if fault == "contentrange" {
+			cr = byteRange{br.first + 1, br.last + 1}.contentRange(size + 1)
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Range", cr)
+		w.Header().Set("Content-Length", strconv.FormatInt(br.last-br.first+1, 10))
+		w.WriteHeader(206)
+		if r.Method != "HEAD" {
+			writeRange(w, rr, br)
+		}
+		log.Info().Msgf("served %v request with X-Request-Id %s code 206 Content-Range %s", r.URL.Path, getXRequestId(r), cr)
+		return
+	}
+
+	headers := make([]string, len(ranges))
+	length := int64(len(fmt.Sprintf("\r\n--%s--\r\n", rangeBoundary)))
+	for i, br := range ranges {
+		cr := br.contentRange(size)
+		if fault == "contentrange" {
+			cr = byteRange{br.first + 1, br.last + 1}.contentRange(size + 1)
+		}
+		headers[i] = fmt.Sprintf("\r\n--%s\r\nContent-Type: application/octet-stream\r\nContent-Range: %s\r\n\r\n", rangeBoundary, cr)
+		length += int64(len(headers[i])) + br.last - br.first + 1
+	}
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+rangeBoundary)
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(206)
+	if r.Method != "HEAD" {
+		for i, br := range ranges {
+			io.WriteString(w, headers[i])
+			writeRange(w, rr, br)
+		}
+		fmt.Fprintf(w, "\r\n--%s--\r\n", rangeBoundary)
+	}
+	log.Info().Msgf("served %v request with X-Request-Id %s code 206 multipart/byteranges with %d parts", r.URL.Path, getXRequestId(r), len(ranges))
+}
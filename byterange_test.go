@@ -0,0 +1,187 @@
+package mse6
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestByteRangeResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(byterange))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		query        string
+		rng          string
+		ifRange      string
+		code         int
+		contentRange string
+		body         string
+	}{
+		{"no range", "?size=10", "", "", 200, "", "0123456789"},
+		{"single", "?size=100", "bytes=10-14", "", 206, "bytes 10-14/100", "abcde"},
+		{"open ended", "?size=100", "bytes=98-", "", 206, "bytes 98-99/100", "qr"},
+		{"suffix", "?size=100", "bytes=-3", "", 206, "bytes 97-99/100", "pqr"},
+		{"unsatisfiable", "?size=100", "bytes=100-200", "", 416, "bytes */100", ""},
+		{"malformed ignored", "?size=10", "bytes=x-y", "", 200, "", "0123456789"},
+		{"if-range etag match", "?size=100", "bytes=0-1", `"mse6-100"`, 206, "bytes 0-1/100", "01"},
+		{"if-range etag mismatch", "?size=10", "bytes=0-1", `"other"`, 200, "", "0123456789"},
+		{"if-range date match", "?size=100", "bytes=0-1", rangeLastModified.Format(http.TimeFormat), 206, "bytes 0-1/100", "01"},
+		{"fault full", "?size=10&fault=full", "bytes=0-1", "", 200, "", "0123456789"},
+		{"fault contentrange", "?size=100&fault=contentrange", "bytes=0-1", "", 206, "bytes 1-2/101", "01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+"/range"+tt.query, nil)
+			if len(tt.rng) > 0 {
+				req.Header.Set("Range", tt.rng)
+			}
+			if len(tt.ifRange) > 0 {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if res.Header.Get("Content-Range") != tt.contentRange {
+				t.Errorf("Content-Range want %v, got %v", tt.contentRange, res.Header.Get("Content-Range"))
+			}
+			if string(b) != tt.body {
+				t.Errorf("body want %v, got %v", tt.body, string(b))
+			}
+			if res.Header.Get("Accept-Ranges") != "bytes" {
+				t.Errorf("want Accept-Ranges bytes, got %v", res.Header.Get("Accept-Ranges"))
+			}
+		})
+	}
+}
+
+func TestByteRangeMultipart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(byterange))
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"multi", "?size=100", []string{"01", "abc"}},
+		{"overlap", "?size=100&fault=overlap", []string{"01", "abc", "0123456789abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+"/range"+tt.query, nil)
+			req.Header.Set("Range", "bytes=0-1,10-12")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			defer res.Body.Close()
+
+			mt, params, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+			if res.StatusCode != 206 || mt != "multipart/byteranges" {
+				t.Errorf("want 206 multipart/byteranges, got %v %v", res.StatusCode, mt)
+				return
+			}
+			mr := multipart.NewReader(res.Body, params["boundary"])
+			for i, w := range tt.want {
+				p, err := mr.NextPart()
+				if err != nil {
+					t.Errorf("part %d missing cause %v", i, err)
+					return
+				}
+				b, _ := ioutil.ReadAll(p)
+				if string(b) != w {
+					t.Errorf("part %d want %v, got %v", i, w, string(b))
+				}
+			}
+			if _, err := mr.NextPart(); err == nil {
+				t.Errorf("want exactly %d parts", len(tt.want))
+			}
+		})
+	}
+}
+
+func TestByteRangeFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "mse6range")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "resume.bin"), []byte("Hello from the range file"), 0644)
+	RangeDir = dir
+	defer func() { RangeDir = "" }()
+
+	srv := httptest.NewServer(http.HandlerFunc(byterange))
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		rng   string
+		code  int
+		body  string
+	}{
+		{"full", "?file=resume.bin", "", 200, "Hello from the range file"},
+		{"range", "?file=resume.bin", "bytes=6-9", 206, "from"},
+		{"missing", "?file=other.bin", "", 404, ""},
+		{"traversal", "?file=../resume.bin", "", 404, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+"/range"+tt.query, nil)
+			if len(tt.rng) > 0 {
+				req.Header.Set("Range", tt.rng)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if tt.code != 404 && string(b) != tt.body {
+				t.Errorf("body want %v, got %v", tt.body, string(b))
+			}
+		})
+	}
+}
+
+func TestByteRangeLargeSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(byterange))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/range?size=10000000000", nil)
+	req.Header.Set("Range", "bytes=9999999990-")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 206 || string(b) != "ijklmnopqr" {
+		t.Errorf("want 206 with the last 10 bytes, got %v %s", res.StatusCode, b)
+	}
+
+	res, _ = http.Get(srv.URL + "/range?size=100000000000")
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("response status code want 400 above size limit, got %v", res.StatusCode)
+	}
+}
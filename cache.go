@@ -0,0 +1,119 @@
+package mse6
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheMaxCounters caps the number of ids whose origin hits are counted.
+const cacheMaxCounters = 1024
+
+// cacheControl builds the Cache-Control directives from the query parameters.
+func cacheControl(r *http.Request) string {
+	q := r.URL.Query()
+	d := make([]string, 0)
+	for _, f := range []struct {
+		param     string
+		directive string
+	}{
+		{"public", "public"},
+		{"private", "private"},
+		{"nostore", "no-store"},
+		{"nocache", "no-cache"},
+		{"mustrevalidate", "must-revalidate"},
+		{"proxyrevalidate", "proxy-revalidate"},
+		{"immutable", "immutable"},
+	} {
+		if q.Get(f.param) == "true" {
+			d = append(d, f.directive)
+		}
+	}
+	for _, f := range []struct {
+		param     string
+		directive string
+	}{
+		{"maxage", "max-age"},
+		{"smaxage", "s-maxage"},
+		{"swr", "stale-while-revalidate"},
+		{"sie", "stale-if-error"},
+	} {
+		if len(q.Get(f.param)) > 0 {
+			d = append(d, fmt.Sprintf("%s=%d", f.directive, parseQueryInt(r, f.param, 0)))
+		}
+	}
+	return strings.Join(d, ", ")
+}
+
+// cacheETag quotes id as the entity-tag, or a hash of it if id has characters outside RFC 7232 etagc.
+func cacheETag(id string) string {
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; c < 0x21 || c == '"' || c == 0x7f {
+			return `"` + sha256Hex([]byte(id))[:32] + `"`
+		}
+	}
+	return `"` + id + `"`
+}
+
+// cache serves a response with configurable caching headers and a counter of origin hits in the body,
+// so caches can be verified to honour freshness and revalidation. The counter is keyed by id, reset=true restarts
+// it. After failafter origin hits every response is 503 to exercise stale-if-error.
+func (s *server) cache(w http.ResponseWriter, r *http.Request) {
+	id := "cache"
+	if len(r.URL.Query().Get("id")) > 0 {
+		id = r.URL.Query().Get("id")
+	}
+	if r.URL.Query().Get("reset") == "true" {
+		s.state.cache.reset(id)
+	}
+	hits := s.state.cache.next(id+"|hits") + 1
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	if cc := cacheControl(r); len(cc) > 0 {
+		w.Header().Set("Cache-Control", cc)
+	}
+	if len(r.URL.Query().Get("expires")) > 0 {
+		exp := time.Now().Add(time.Duration(parseQueryInt(r, "expires", 0)) * time.Second)
+		w.Header().Set("Expires", exp.UTC().Format(http.TimeFormat))
+	}
+	if len(r.URL.Query().Get("age")) > 0 {
+		w.Header().Set("Age", strconv.Itoa(parseQueryInt(r, "age", 0)))
+	}
+	if v := r.URL.Query().Get("vary"); len(v) > 0 {
+		w.Header().Set("Vary", v)
+	}
+
+	if fa := parseQueryInt(r, "failafter", 0); fa > 0 && hits > fa {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(503)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"503", "hits":%d}`, hits)))
+		log.Info().Msgf("served %v request with X-Request-Id %s code 503 after %d origin hits for %s", r.URL.Path, getXRequestId(r), hits, id)
+		return
+	}
+
+	//the etag is stable per id so caches can revalidate, the body still changes with every origin hit.
+	etag := cacheETag(id)
+	w.Header().Set("ETag", etag)
+	code := 200
+	if inm := r.Header.Get("If-None-Match"); len(inm) > 0 && etagListMatches(inm, etag, true) {
+		code = 304
+		w.WriteHeader(code)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if r.Method != "HEAD" {
+			b, _ := json.Marshal(struct {
+				Mse6 string `json:"mse6"`
+				ID   string `json:"id"`
+				Hits int    `json:"hits"`
+			}{"Hello from the cache endpoint", id, hits})
+			w.Write(b)
+		}
+	}
+	log.Info().Msgf("served %v request with X-Request-Id %s code %d origin hit %d for %s Cache-Control %q", r.URL.Path, getXRequestId(r), code, hits, id, w.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,142 @@
+package mse6
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCacheResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().cache))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		query  string
+		header string
+		want   string
+	}{
+		{"cache-control", "?maxage=60&smaxage=120&swr=30&sie=300&public=true", "Cache-Control", "public, max-age=60, s-maxage=120, stale-while-revalidate=30, stale-if-error=300"},
+		{"no-store", "?nostore=true", "Cache-Control", "no-store"},
+		{"age", "?age=42", "Age", "42"},
+		{"vary", "?vary=Accept-Encoding,Accept-Language", "Vary", "Accept-Encoding,Accept-Language"},
+		{"no headers", "", "Cache-Control", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Get(srv.URL + "/cache" + tt.query)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			res.Body.Close()
+			if res.Header.Get(tt.header) != tt.want {
+				t.Errorf("%s want %v, got %v", tt.header, tt.want, res.Header.Get(tt.header))
+			}
+		})
+	}
+}
+
+func TestCacheCountsOriginHits(t *testing.T) {
+	s := newServer()
+	srv := httptest.NewServer(http.HandlerFunc(s.cache))
+	defer srv.Close()
+	s.state.cache.reset("TestCacheCountsOriginHits")
+
+	url := srv.URL + "/cache?id=TestCacheCountsOriginHits&failafter=3&expires=60"
+	tests := []struct {
+		inm  string
+		code int
+		want string
+	}{
+		{"", 200, `"hits":1`},
+		{"", 200, `"hits":2`},
+		{`"TestCacheCountsOriginHits"`, 304, ""},
+		{"", 503, `"hits":4`},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest("GET", url, nil)
+		if len(tt.inm) > 0 {
+			req.Header.Set("If-None-Match", tt.inm)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tt.code {
+			t.Errorf("request %d status code want %v, got %v", i, tt.code, res.StatusCode)
+		}
+		if !strings.Contains(string(b), tt.want) {
+			t.Errorf("request %d body want %v, got %s", i, tt.want, b)
+		}
+		if tt.code == 200 && len(res.Header.Get("Expires")) == 0 {
+			t.Errorf("request %d want Expires header", i)
+		}
+	}
+}
+
+func TestCacheResetsOriginHits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().cache))
+	defer srv.Close()
+
+	for i, want := range []string{`"hits":1`, `"hits":2`, `"hits":1`} {
+		q := "?id=TestCacheResetsOriginHits"
+		if i == 2 {
+			q += "&reset=true"
+		}
+		res, err := http.Get(srv.URL + q)
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(b), want) {
+			t.Errorf("request %d body want %v, got %s", i, want, b)
+		}
+	}
+}
+
+func TestCacheQuotesId(t *testing.T) {
+	id := `a"b\c`
+	s := newServer()
+	w := httptest.NewRecorder()
+	s.cache(w, httptest.NewRequest("GET", "/cache?id="+url.QueryEscape(id), nil))
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.ID != id {
+		t.Errorf("want valid JSON with id %s, got %s cause %v", id, w.Body.String(), err)
+	}
+	etag := w.Header().Get("ETag")
+	if len(etag) < 2 || strings.Contains(etag[1:len(etag)-1], `"`) {
+		t.Errorf("want valid entity-tag, got %s", etag)
+	}
+
+	req := httptest.NewRequest("GET", "/cache?id="+url.QueryEscape(id), nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.cache(w, req)
+	if w.Code != 304 {
+		t.Errorf("want 304 revalidating with ETag, got %v", w.Code)
+	}
+}
+
+func TestCacheCapsCounters(t *testing.T) {
+	s := newStateStore().cache
+	for i := 0; i <= cacheMaxCounters; i++ {
+		s.next(fmt.Sprintf("id%d|hits", i))
+	}
+	if len(s.counters) != cacheMaxCounters {
+		t.Errorf("want %d counters, got %d", cacheMaxCounters, len(s.counters))
+	}
+}
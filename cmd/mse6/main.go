@@ -28,6 +28,7 @@ func main() {
 	port := flag.Int("p", 8081, "the http port")
 	u := flag.String("u", "/mse6/", "the path prefix")
 	tlsMode := flag.Bool("s", false, "self signed tls mode")
+	d := flag.String("d", "", "directory of files served by the range endpoint")
 	tM := flag.Bool("t", false, "server self test")
 	h := flag.Bool("h", false, "print usage instructions")
 	vM := flag.Bool("v", false, "print the server version")
@@ -48,6 +49,7 @@ func main() {
 
 	switch mode {
 	case Server:
+		mse6.RangeDir = *d
 		mse6.Bootstrap(*port, pattern, *tlsMode)
 	case Test:
 		printSelftest(*port)
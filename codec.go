@@ -0,0 +1,203 @@
+package mse6
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Codec is a HTTP content coding. Encode wraps w in a streaming encoder that must be closed to finish the
+// stream, Decode wraps r in a streaming decoder that should be closed once read.
+type Codec interface {
+	Name() string
+	Encode(w io.Writer) (io.WriteCloser, error)
+	Decode(r io.Reader) (io.ReadCloser, error)
+}
+
+type codecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+	names  []string
+}
+
+var codecs = &codecRegistry{codecs: make(map[string]Codec)}
+
+func init() {
+	codecs.register(&gzipCodec{})
+	codecs.register(newDeflateCodec())
+	codecs.register(newBrotliCodec())
+	codecs.register(newZstdCodec())
+}
+
+// register adds the codec, or replaces a codec with the same name.
+func (c *codecRegistry) register(codec Codec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.codecs[codec.Name()]; !ok {
+		c.names = append(c.names, codec.Name())
+	}
+	c.codecs[codec.Name()] = codec
+}
+
+func (c *codecRegistry) get(name string) (Codec, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	codec, ok := c.codecs[name]
+	return codec, ok
+}
+
+// list returns the names of all codecs in the order they were registered.
+func (c *codecRegistry) list() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := make([]string, len(c.names))
+	copy(names, c.names)
+	return names
+}
+
+// encodeBytes encodes input with the named codec.
+func encodeBytes(name string, input []byte) ([]byte, error) {
+	codec, ok := codecs.get(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported content coding %s", name)
+	}
+	buf := &bytes.Buffer{}
+	wrt, err := codec.Encode(buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = wrt.Write(input); err != nil {
+		wrt.Close()
+		return nil, err
+	}
+	if err = wrt.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeBytes decodes input with the named codec. Corrupt or truncated input returns an error.
+func decodeBytes(name string, input []byte) ([]byte, error) {
+	codec, ok := codecs.get(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported content coding %s", name)
+	}
+	rd, err := codec.Decode(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	dec, err := ioutil.ReadAll(rd)
+	rd.Close()
+	return dec, err
+}
+
+// sendEncoded sends body with 200 encoded by the named codec, or 500 if encoding fails.
+func sendEncoded(w http.ResponseWriter, r *http.Request, name string, body []byte) {
+	b, err := encodeBytes(name, body)
+	if err != nil {
+		sendEncodingError(w, r, name, err)
+		return
+	}
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", name)
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
+func sendEncodingError(w http.ResponseWriter, r *http.Request, name string, err error) {
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.WriteHeader(500)
+	w.Write([]byte(`{"mse6":"500"}`))
+	log.Error().Msgf("unable to encode %v response with X-Request-Id %s as %s, cause: %v", r.URL.Path, getXRequestId(r), name, err)
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// pooledWriter returns the encoder to its pool on Close, unless writing or closing failed.
+type pooledWriter struct {
+	enc  resetWriter
+	pool *sync.Pool
+	err  error
+}
+
+func (p *pooledWriter) Write(b []byte) (int, error) {
+	n, err := p.enc.Write(b)
+	if err != nil {
+		p.err = err
+	}
+	return n, err
+}
+
+// Flush writes any pending data as a complete block so that it can be decoded before the stream ends.
+func (p *pooledWriter) Flush() error {
+	err := p.enc.Flush()
+	if err != nil {
+		p.err = err
+	}
+	return err
+}
+
+func (p *pooledWriter) Close() error {
+	if p.pool == nil {
+		return p.err
+	}
+	err := p.enc.Close()
+	if err == nil && p.err == nil {
+		p.enc.Reset(nil)
+		p.pool.Put(p.enc)
+	}
+	p.pool = nil
+	if err != nil {
+		return err
+	}
+	return p.err
+}
+
+func newPooledWriter(pool *sync.Pool, w io.Writer) *pooledWriter {
+	enc := pool.Get().(resetWriter)
+	enc.Reset(w)
+	return &pooledWriter{enc: enc, pool: pool}
+}
+
+// pooledReader returns the decoder to its pool on Close only if it was read to the end without error.
+// Decoders that failed are discarded so that no poisoned state is reused.
+type pooledReader struct {
+	dec     io.Reader
+	item    interface{}
+	pool    *sync.Pool
+	discard func()
+	eof     bool
+	err     error
+}
+
+func (p *pooledReader) Read(b []byte) (int, error) {
+	n, err := p.dec.Read(b)
+	if err == io.EOF {
+		p.eof = true
+	} else if err != nil {
+		p.err = err
+	}
+	return n, err
+}
+
+func (p *pooledReader) Close() error {
+	if p.pool == nil {
+		return nil
+	}
+	if p.eof && p.err == nil {
+		p.pool.Put(p.item)
+	} else if p.discard != nil {
+		p.discard()
+	}
+	p.pool = nil
+	return nil
+}
@@ -0,0 +1,82 @@
+package mse6
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCodecsRegistered(t *testing.T) {
+	want := []string{"gzip", "deflate", "br", "zstd"}
+	got := codecs.list()
+	if len(got) != len(want) {
+		t.Errorf("want codecs %v, got %v", want, got)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("want codecs %v, got %v", want, got)
+		}
+	}
+	if _, ok := codecs.get("compress"); ok {
+		t.Errorf("want compress not registered")
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	plain := bytes.Repeat([]byte("ResistanceIsFutile"), 1000)
+	for _, name := range codecs.list() {
+		t.Run(name, func(t *testing.T) {
+			codec, _ := codecs.get(name)
+			buf := &bytes.Buffer{}
+			wrt, err := codec.Encode(buf)
+			if err != nil {
+				t.Errorf("unable to create encoder cause %v", err)
+				return
+			}
+			for i := 0; i < 10; i++ {
+				wrt.Write(plain[i*1800 : (i+1)*1800])
+			}
+			if err = wrt.Close(); err != nil {
+				t.Errorf("unable to close encoder cause %v", err)
+			}
+
+			rd, err := codec.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Errorf("unable to create decoder cause %v", err)
+				return
+			}
+			got, err := ioutil.ReadAll(rd)
+			rd.Close()
+			if err != nil || !bytes.Equal(got, plain) {
+				t.Errorf("want %d decoded bytes, got %d cause %v", len(plain), len(got), err)
+			}
+		})
+	}
+}
+
+func TestCodecsTruncatedInput(t *testing.T) {
+	plain := bytes.Repeat([]byte("MaryHadALittleLamb"), 1000)
+	for _, name := range codecs.list() {
+		t.Run(name, func(t *testing.T) {
+			enc, _ := encodeBytes(name, plain)
+			if _, err := decodeBytes(name, enc[:len(enc)/2]); err == nil {
+				t.Errorf("want error for truncated %s input", name)
+			}
+			// a failed decoder must not poison the next decode
+			got, err := decodeBytes(name, enc)
+			if err != nil || !bytes.Equal(got, plain) {
+				t.Errorf("want %d decoded bytes after failed decode, got %d cause %v", len(plain), len(got), err)
+			}
+		})
+	}
+}
+
+func TestCodecsUnsupported(t *testing.T) {
+	if _, err := encodeBytes("compress", []byte("mse6")); err == nil {
+		t.Errorf("want error for unsupported encoding")
+	}
+	if _, err := decodeBytes("compress", []byte("mse6")); err == nil {
+		t.Errorf("want error for unsupported decoding")
+	}
+}
@@ -0,0 +1,137 @@
+package mse6
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+)
+
+const bombBlockSize = 64 * 1024
+
+// corruptChecksum flips a bit in the gzip CRC32 or the zstd frame checksum. deflate and brotli have no checksum,
+// so a bit in the middle of the stream is flipped instead.
+func corruptChecksum(codec string, b []byte) []byte {
+	switch codec {
+	case "gzip":
+		b[len(b)-8] ^= 0x01
+	case "zstd":
+		b[len(b)-1] ^= 0x01
+	default:
+		b[len(b)/2] ^= 0x01
+	}
+	return b
+}
+
+// compressionFault encodes the body with the fault applied and returns it with the Content-Encoding to send.
+func compressionFault(codec string, fault string, body []byte) ([]byte, string, error) {
+	b, err := encodeBytes(codec, body)
+	if err != nil {
+		return nil, codec, err
+	}
+	switch fault {
+	case "truncate":
+		return b[:len(b)/2], codec, nil
+	case "checksum":
+		return corruptChecksum(codec, b), codec, nil
+	case "trailing":
+		return append(b, []byte("mse6 trailing garbage")...), codec, nil
+	case "concat":
+		return append(b, b...), codec, nil
+	case "mismatch":
+		other := "br"
+		if codec == "br" {
+			other = "gzip"
+		}
+		b, err = encodeBytes(other, body)
+		return b, codec, err
+	case "double":
+		b, err = encodeBytes(codec, b)
+		return b, codec, err
+	}
+	return b, codec, nil
+}
+
+// compressionfault sends a compressed body with a fault, selected by codec=gzip|br|deflate|zstd and
+// fault=truncate|checksum|trailing|concat|mismatch|double|bomb.
+func compressionfault(w http.ResponseWriter, r *http.Request) {
+	codec := r.URL.Query().Get("codec")
+	if len(codec) == 0 {
+		codec = "gzip"
+	}
+	fault := r.URL.Query().Get("fault")
+	c, ok := codecs.get(codec)
+	if !ok {
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"400", "error":"unsupported codec %s"}`, codec)))
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 unsupported codec %s", r.URL.Path, getXRequestId(r), codec)
+		return
+	}
+
+	if fault == "bomb" {
+		compressionbomb(w, r, c)
+		return
+	}
+
+	b, ce, err := compressionFault(codec, fault, []byte(`{"mse6":"Hello from the compressionfault endpoint"}`))
+	if err != nil {
+		sendEncodingError(w, r, codec, err)
+		return
+	}
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", ce)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(b)
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 codec %s fault %s", r.URL.Path, getXRequestId(r), codec, fault)
+}
+
+// compressionbomb streams size MB of zeros, decoded, compressed with codec. ratio lowers the expansion by mixing
+// incompressible random bytes into every block, the default sends nothing but zeros for the highest ratio.
+func compressionbomb(w http.ResponseWriter, r *http.Request, c Codec) {
+	codec := c.Name()
+	size := int64(parseQueryInt(r, "size", 64)) * 1024 * 1024
+	ratio := parseQueryInt(r, "ratio", 0)
+	random := 0
+	if ratio > 0 {
+		random = bombBlockSize / ratio
+	}
+	if random > bombBlockSize {
+		random = bombBlockSize
+	}
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", codec)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Mse6-Decoded-Length", strconv.FormatInt(size, 10))
+	cw, err := c.Encode(w)
+	if err != nil {
+		sendEncodingError(w, r, codec, err)
+		return
+	}
+	w.WriteHeader(200)
+
+	block := make([]byte, bombBlockSize)
+	for written := int64(0); written < size; written += bombBlockSize {
+		for i := range block {
+			block[i] = 0
+		}
+		rand.Read(block[:random])
+		n := int64(bombBlockSize)
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := cw.Write(block[:n]); err != nil {
+			log.Info().Msgf("aborted %v request with X-Request-Id %s compression bomb after %d bytes, cause: %v", r.URL.Path, getXRequestId(r), written, err)
+			return
+		}
+	}
+	if err := cw.Close(); err != nil {
+		log.Info().Msgf("aborted %v request with X-Request-Id %s compression bomb on close, cause: %v", r.URL.Path, getXRequestId(r), err)
+		return
+	}
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 %s compression bomb of %d decoded bytes", r.URL.Path, getXRequestId(r), codec, size)
+}
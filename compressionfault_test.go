@@ -0,0 +1,118 @@
+package mse6
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompressionFaultGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(compressionfault))
+	defer srv.Close()
+
+	tests := []struct {
+		fault   string
+		wantErr bool
+	}{
+		{"", false},
+		{"truncate", true},
+		{"checksum", true},
+		{"trailing", true},
+		{"concat", false},
+		{"mismatch", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fault, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+"/compressionfault?codec=gzip&fault="+tt.fault, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			res, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+
+			_, err = func() ([]byte, error) {
+				gz, err := gzip.NewReader(bytes.NewReader(b))
+				if err != nil {
+					return nil, err
+				}
+				return ioutil.ReadAll(gz)
+			}()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("gzip fault %q want error %v, got %v", tt.fault, tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCompressionFaultZstdChecksum(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(compressionfault))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/compressionfault?codec=zstd&fault=checksum")
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	d, _ := zstd.NewReader(bytes.NewReader(b))
+	defer d.Close()
+	if _, err := ioutil.ReadAll(d); err == nil {
+		t.Errorf("want zstd checksum error")
+	}
+}
+
+func TestCompressionFaultDouble(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(compressionfault))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/compressionfault?codec=br&fault=double")
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	once, _ := BrotliDecode(b)
+	if twice, _ := BrotliDecode(once); string(twice) != `{"mse6":"Hello from the compressionfault endpoint"}` {
+		t.Errorf("want body compressed twice, got %s", once)
+	}
+}
+
+func TestCompressionBomb(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(compressionfault))
+	defer srv.Close()
+
+	tests := []struct {
+		query    string
+		minRatio int64
+		maxRatio int64
+	}{
+		{"?codec=gzip&fault=bomb&size=4", 500, 2000},
+		{"?codec=zstd&fault=bomb&size=4&ratio=10", 5, 20},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", srv.URL+tt.query, nil)
+		req.Header.Set("Accept-Encoding", "identity")
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return
+		}
+		n, _ := io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+		ratio := int64(4*1024*1024) / n
+		if ratio < tt.minRatio || ratio > tt.maxRatio {
+			t.Errorf("%s want expansion ratio between %d and %d, got %d", tt.query, tt.minRatio, tt.maxRatio, ratio)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package mse6
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type conditionalResource struct {
+	version  int
+	body     []byte
+	modified time.Time
+}
+
+func (c conditionalResource) etag(weak bool) string {
+	if weak {
+		return fmt.Sprintf(`W/"v%d"`, c.version)
+	}
+	return fmt.Sprintf(`"v%d"`, c.version)
+}
+
+// conditionalMaxResources caps the number of ids kept, the least recently modified resource is evicted beyond it.
+const conditionalMaxResources = 1024
+
+type conditionalStore struct {
+	mu        sync.Mutex
+	resources map[string]conditionalResource
+}
+
+func newConditionalResource(id string, version int) conditionalResource {
+	body, _ := json.Marshal(struct {
+		Mse6    string `json:"mse6"`
+		ID      string `json:"id"`
+		Version int    `json:"version"`
+	}{"Hello from the conditional endpoint", id, version})
+	return conditionalResource{
+		version:  version,
+		body:     body,
+		modified: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// put stores c under id, evicting the least recently modified resource if a new id exceeds the cap.
+// The caller must hold mu.
+func (s *conditionalStore) put(id string, c conditionalResource) {
+	if _, ok := s.resources[id]; !ok && len(s.resources) >= conditionalMaxResources {
+		oldest := ""
+		for k, v := range s.resources {
+			if len(oldest) == 0 || v.modified.Before(s.resources[oldest].modified) {
+				oldest = k
+			}
+		}
+		delete(s.resources, oldest)
+	}
+	s.resources[id] = c
+}
+
+// get returns the resource for id, creating version 1 on first use.
+func (s *conditionalStore) get(id string) conditionalResource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.resources[id]
+	if !ok {
+		c = newConditionalResource(id, 1)
+		s.put(id, c)
+	}
+	return c
+}
+
+// update evaluates the preconditions against the current version and replaces it with body if they hold.
+// It returns the resulting resource and the status code of a failed precondition, or 0.
+func (s *conditionalStore) update(id string, body []byte, check func(conditionalResource) int) (conditionalResource, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.resources[id]
+	if !ok {
+		c = newConditionalResource(id, 1)
+	}
+	if code := check(c); code != 0 {
+		s.put(id, c)
+		return c, code
+	}
+	c = conditionalResource{version: c.version + 1, body: body, modified: time.Now().UTC().Truncate(time.Second)}
+	if len(body) == 0 {
+		c = newConditionalResource(id, c.version)
+	}
+	s.put(id, c)
+	return c, 0
+}
+
+// etagListMatches compares etag against a comma separated If-Match or If-None-Match list.
+func etagListMatches(list string, etag string, weak bool) bool {
+	opaque := func(e string) string {
+		if weak {
+			return strings.TrimPrefix(e, "W/")
+		}
+		return e
+	}
+	for _, e := range strings.Split(list, ",") {
+		e = strings.TrimSpace(e)
+		if e == "*" {
+			return true
+		}
+		if !weak && (strings.HasPrefix(e, "W/") || strings.HasPrefix(etag, "W/")) {
+			continue
+		}
+		if opaque(e) == opaque(etag) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePreconditions follows the RFC 7232 section 6 order of precedence and returns 304, 412 or 0.
+func evaluatePreconditions(r *http.Request, c conditionalResource, etag string) int {
+	if im := r.Header.Get("If-Match"); len(im) > 0 {
+		if !etagListMatches(im, etag, false) {
+			return 412
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); len(ius) > 0 {
+		if t, err := http.ParseTime(ius); err == nil && c.modified.After(t) {
+			return 412
+		}
+	}
+	safe := r.Method == "GET" || r.Method == "HEAD"
+	if inm := r.Header.Get("If-None-Match"); len(inm) > 0 {
+		if etagListMatches(inm, etag, true) {
+			if safe {
+				return 304
+			}
+			return 412
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); len(ims) > 0 && safe {
+		if t, err := http.ParseTime(ims); err == nil && !c.modified.After(t) {
+			return 304
+		}
+	}
+	return 0
+}
+
+// conditional serves a versioned resource keyed by id with ETag and Last-Modified validators. PUT replaces
+// the resource and increments its version, require=true rejects PUT without If-Match with 428. weak=true sends
+// weak ETags, fault=304body sends a body with 304 and fault=etag sends a different ETag with every response.
+func (s *server) conditional(w http.ResponseWriter, r *http.Request) {
+	id := "conditional"
+	if len(r.URL.Query().Get("id")) > 0 {
+		id = r.URL.Query().Get("id")
+	}
+	weak := r.URL.Query().Get("weak") == "true"
+	fault := r.URL.Query().Get("fault")
+	etagFor := func(c conditionalResource) string {
+		if fault == "etag" {
+			return fmt.Sprintf(`"v%d-%d"`, c.version, time.Now().UnixNano())
+		}
+		return c.etag(weak)
+	}
+
+	var c conditionalResource
+	code := 0
+	if r.Method == "PUT" {
+		body, _ := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if r.URL.Query().Get("require") == "true" && len(r.Header.Get("If-Match")) == 0 {
+			w.Header().Set("Server", "mse6 "+Version)
+			w.Header().Set("Content-Encoding", "identity")
+			w.WriteHeader(428)
+			w.Write([]byte(`{"mse6":"428"}`))
+			log.Info().Msgf("served %v request with X-Request-Id %s code 428 PUT without If-Match", r.URL.Path, getXRequestId(r))
+			return
+		}
+		c, code = s.state.conditional.update(id, body, func(cur conditionalResource) int {
+			return evaluatePreconditions(r, cur, cur.etag(weak))
+		})
+	} else {
+		c = s.state.conditional.get(id)
+		code = evaluatePreconditions(r, c, c.etag(weak))
+	}
+	etag := etagFor(c)
+
+	if code == 304 && fault == "304body" {
+		hj, _ := w.(http.Hijacker)
+		conn, bufrw, _ := hj.Hijack()
+		defer conn.Close()
+		bufrw.WriteString("HTTP/1.1 304 Not Modified\r\n")
+		bufrw.WriteString(fmt.Sprintf("Server: mse6 %s\r\nETag: %s\r\nContent-Length: %d\r\nConnection: close\r\n\r\n", Version, etag, len(c.body)))
+		bufrw.Write(c.body)
+		bufrw.Flush()
+		log.Info().Msgf("served %v request with X-Request-Id %s code 304 with illegal body", r.URL.Path, getXRequestId(r))
+		return
+	}
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", c.modified.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache")
+	switch code {
+	case 304:
+		w.WriteHeader(304)
+	case 412:
+		w.WriteHeader(412)
+		w.Write([]byte(`{"mse6":"412"}`))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		if r.Method != "HEAD" {
+			w.Write(c.body)
+		}
+		code = 200
+	}
+	log.Info().Msgf("served %v %s request with X-Request-Id %s code %d resource %s version %d ETag %s", r.URL.Path, r.Method, getXRequestId(r), code, id, c.version, etag)
+}
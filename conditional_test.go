@@ -0,0 +1,131 @@
+package mse6
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().conditional))
+	defer srv.Close()
+
+	url := srv.URL + "/conditional?id=TestConditionalResponds"
+	res, _ := http.Get(url)
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+	lm := res.Header.Get("Last-Modified")
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		value  string
+		code   int
+	}{
+		{"if-none-match", "GET", "If-None-Match", etag, 304},
+		{"if-none-match weak", "GET", "If-None-Match", "W/" + etag, 304},
+		{"if-none-match other", "GET", "If-None-Match", `"other"`, 200},
+		{"if-modified-since", "GET", "If-Modified-Since", lm, 304},
+		{"if-modified-since past", "GET", "If-Modified-Since", past, 200},
+		{"if-match other", "GET", "If-Match", `"other"`, 412},
+		{"if-unmodified-since past", "GET", "If-Unmodified-Since", past, 412},
+		{"put if-match stale", "PUT", "If-Match", `"v0"`, 412},
+		{"put if-none-match star", "PUT", "If-None-Match", "*", 412},
+		{"put if-match", "PUT", "If-Match", etag, 200},
+		{"if-none-match after put", "GET", "If-None-Match", etag, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, url, bytes.NewBufferString(`{"mse6":"updated"}`))
+			req.Header.Set(tt.header, tt.value)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestConditionalRequiresIfMatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().conditional))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("PUT", srv.URL+"/conditional?id=TestConditionalRequiresIfMatch&require=true", bytes.NewBufferString("{}"))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != 428 {
+		t.Errorf("response status code want 428, got %v", res.StatusCode)
+	}
+}
+
+func TestConditionalFaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().conditional))
+	defer srv.Close()
+
+	url := srv.URL + "/conditional?id=TestConditionalFaults&fault=etag"
+	res1, _ := http.Get(url)
+	res1.Body.Close()
+	res2, _ := http.Get(url)
+	res2.Body.Close()
+	if res1.Header.Get("ETag") == res2.Header.Get("ETag") {
+		t.Errorf("want inconsistent ETags, got %v twice", res1.Header.Get("ETag"))
+	}
+
+	//the go client discards bodies of 304 responses, so read the raw response.
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Errorf("unable to connect cause %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /conditional?id=TestConditionalFaults&fault=304body HTTP/1.1\r\nHost: mse6\r\nIf-None-Match: *\r\n\r\n"))
+	raw, _ := ioutil.ReadAll(conn)
+	if !strings.HasPrefix(string(raw), "HTTP/1.1 304") || !strings.HasSuffix(string(raw), "}") {
+		t.Errorf("want 304 with body, got %s", raw)
+	}
+}
+
+func TestConditionalEscapesId(t *testing.T) {
+	id := `a"b\c`
+	w := httptest.NewRecorder()
+	newServer().conditional(w, httptest.NewRequest("GET", "/conditional?id="+url.QueryEscape(id), nil))
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.ID != id {
+		t.Errorf("want valid JSON with id %s, got %s cause %v", id, w.Body.String(), err)
+	}
+}
+
+func TestConditionalStoreCapsResources(t *testing.T) {
+	s := newStateStore().conditional
+	for i := 0; i <= conditionalMaxResources; i++ {
+		s.get(fmt.Sprintf("id%d", i))
+	}
+	if len(s.resources) != conditionalMaxResources {
+		t.Errorf("want %d resources, got %d", conditionalMaxResources, len(s.resources))
+	}
+	if _, ok := s.resources[fmt.Sprintf("id%d", conditionalMaxResources)]; !ok {
+		t.Errorf("want newest resource kept")
+	}
+}
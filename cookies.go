@@ -0,0 +1,126 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var malformedCookies = []string{
+	`mse6`,
+	`=mse6`,
+	`mse6="unterminated`,
+	`mse6=value; Max-Age=abc`,
+	`mse6=value; Expires=yesterday`,
+	`mse6=value; Domain=..mse6..`,
+	`mse6=value; SameSite=Sometimes`,
+	`mse 6=value`,
+	`mse6=val;ue; Path`,
+	`mse6=` + strings.Repeat("x", 8192),
+}
+
+// setCookieHeader builds the Set-Cookie value by hand, http.Cookie doesn't support every attribute.
+func setCookieHeader(r *http.Request, name string, value string) string {
+	q := r.URL.Query()
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s=%s", name, value)
+	if len(q.Get("domain")) > 0 {
+		fmt.Fprintf(b, "; Domain=%s", q.Get("domain"))
+	}
+	if len(q.Get("path")) > 0 {
+		fmt.Fprintf(b, "; Path=%s", q.Get("path"))
+	}
+	if len(q.Get("maxage")) > 0 {
+		fmt.Fprintf(b, "; Max-Age=%d", parseQueryInt(r, "maxage", 0))
+	}
+	if len(q.Get("expires")) > 0 {
+		exp := time.Now().Add(time.Duration(parseQueryInt(r, "expires", 0)) * time.Second)
+		fmt.Fprintf(b, "; Expires=%s", exp.UTC().Format(http.TimeFormat))
+	}
+	if q.Get("secure") == "true" {
+		b.WriteString("; Secure")
+	}
+	if q.Get("httponly") == "true" {
+		b.WriteString("; HttpOnly")
+	}
+	switch strings.ToLower(q.Get("samesite")) {
+	case "lax":
+		b.WriteString("; SameSite=Lax")
+	case "strict":
+		b.WriteString("; SameSite=Strict")
+	case "none":
+		b.WriteString("; SameSite=None")
+	}
+	if q.Get("partitioned") == "true" {
+		b.WriteString("; Partitioned")
+	}
+	return b.String()
+}
+
+func sendSetCookie(w http.ResponseWriter, r *http.Request, cookies []string) {
+	for _, c := range cookies {
+		w.Header().Add("Set-Cookie", c)
+	}
+	sendJSON(w, 200, map[string][]string{"setCookie": cookies})
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 Set-Cookie %s", r.URL.Path, getXRequestId(r), strings.Join(cookies, " | "))
+}
+
+// setcookie sets the cookie name=value with the attributes from the query. overwrite=true sets the cookie twice
+// in the same response, the second with the value suffixed by -overwritten.
+func setcookie(w http.ResponseWriter, r *http.Request) {
+	name, value := "mse6", "mse6"
+	if len(r.URL.Query().Get("name")) > 0 {
+		name = r.URL.Query().Get("name")
+	}
+	if len(r.URL.Query().Get("value")) > 0 {
+		value = r.URL.Query().Get("value")
+	}
+	cookies := []string{setCookieHeader(r, name, value)}
+	if r.URL.Query().Get("overwrite") == "true" {
+		cookies = append(cookies, setCookieHeader(r, name, value+"-overwritten"))
+	}
+	sendSetCookie(w, r, cookies)
+}
+
+// deletecookie expires the cookie name with Max-Age=0 and an Expires date in the past. Domain and path must
+// match the original cookie for clients to delete it.
+func deletecookie(w http.ResponseWriter, r *http.Request) {
+	name := "mse6"
+	if len(r.URL.Query().Get("name")) > 0 {
+		name = r.URL.Query().Get("name")
+	}
+	c := fmt.Sprintf("%s=; Max-Age=0; Expires=%s", name, time.Unix(0, 0).UTC().Format(http.TimeFormat))
+	if len(r.URL.Query().Get("domain")) > 0 {
+		c += "; Domain=" + r.URL.Query().Get("domain")
+	}
+	if len(r.URL.Query().Get("path")) > 0 {
+		c += "; Path=" + r.URL.Query().Get("path")
+	}
+	sendSetCookie(w, r, []string{c})
+}
+
+// badcookie sends a malformed Set-Cookie header, selected by n.
+func badcookie(w http.ResponseWriter, r *http.Request) {
+	n := parseQueryInt(r, "n", 0)
+	if n < 0 || n >= len(malformedCookies) {
+		n = 0
+	}
+	sendSetCookie(w, r, []string{malformedCookies[n]})
+}
+
+type echoCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cookies echoes the cookies received as JSON.
+func cookies(w http.ResponseWriter, r *http.Request) {
+	cs := make([]echoCookie, 0)
+	for _, c := range r.Cookies() {
+		cs = append(cs, echoCookie{Name: c.Name, Value: c.Value})
+	}
+	sendJSON(w, 200, map[string]interface{}{"cookies": cs, "raw": r.Header["Cookie"]})
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 echoing %d cookies", r.URL.Path, getXRequestId(r), len(cs))
+}
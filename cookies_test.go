@@ -0,0 +1,101 @@
+package mse6
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetCookieAttributes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(setcookie))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/setcookie?name=a&value=b&domain=mse6.local&path=/p&maxage=60&expires=60&secure=true&httponly=true&samesite=strict&partitioned=true")
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+	c := res.Header.Get("Set-Cookie")
+	for _, want := range []string{"a=b", "Domain=mse6.local", "Path=/p", "Max-Age=60", "Expires=", "Secure", "HttpOnly", "SameSite=Strict", "Partitioned"} {
+		if !strings.Contains(c, want) {
+			t.Errorf("want Set-Cookie containing %v, got %v", want, c)
+		}
+	}
+}
+
+func TestCookieJarRoundTrip(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/setcookie", setcookie)
+	mux.HandleFunc("/deletecookie", deletecookie)
+	mux.HandleFunc("/cookies", cookies)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	received := func() map[string]string {
+		res, err := client.Get(srv.URL + "/cookies")
+		if err != nil {
+			t.Fatalf("server did not return ok cause %v", err)
+		}
+		defer res.Body.Close()
+		var echo struct {
+			Cookies []echoCookie `json:"cookies"`
+		}
+		json.NewDecoder(res.Body).Decode(&echo)
+		m := make(map[string]string)
+		for _, c := range echo.Cookies {
+			m[c.Name] = c.Value
+		}
+		return m
+	}
+
+	steps := []struct {
+		url  string
+		want map[string]string
+	}{
+		{"/setcookie?name=session&value=1&maxage=60", map[string]string{"session": "1"}},
+		{"/setcookie?name=session&value=2&overwrite=true", map[string]string{"session": "2-overwritten"}},
+		{"/setcookie?name=scoped&value=1&path=/other", map[string]string{"session": "2-overwritten"}},
+		{"/setcookie?name=expired&value=1&maxage=-1", map[string]string{"session": "2-overwritten"}},
+		{"/deletecookie?name=session", map[string]string{}},
+	}
+	for _, s := range steps {
+		res, err := client.Get(srv.URL + s.url)
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return
+		}
+		res.Body.Close()
+		got := received()
+		if len(got) != len(s.want) {
+			t.Errorf("after %s want cookies %v, got %v", s.url, s.want, got)
+		}
+		for k, v := range s.want {
+			if got[k] != v {
+				t.Errorf("after %s want cookie %s=%s, got %v", s.url, k, v, got)
+			}
+		}
+	}
+}
+
+func TestBadCookieResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(badcookie))
+	defer srv.Close()
+
+	for i := range malformedCookies {
+		res, err := http.Get(srv.URL + "/badcookie?n=" + string(rune('0'+i)))
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return
+		}
+		res.Body.Close()
+		if res.Header.Get("Set-Cookie") != malformedCookies[i] {
+			t.Errorf("want malformed cookie %d, got %v", i, res.Header.Get("Set-Cookie"))
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type corsDecision struct {
+	Origin    string `json:"origin"`
+	Preflight bool   `json:"preflight"`
+	Allowed   bool   `json:"allowed"`
+	Reason    string `json:"reason"`
+}
+
+func corsContains(list []string, v string) bool {
+	for _, l := range list {
+		if l == "*" || strings.EqualFold(l, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsDecide evaluates the request against the configured origins, methods and headers.
+func corsDecide(r *http.Request, preflight bool) corsDecision {
+	d := corsDecision{Origin: r.Header.Get("Origin"), Preflight: preflight, Allowed: true, Reason: "allowed"}
+	if len(d.Origin) == 0 {
+		d.Allowed, d.Reason = false, "no Origin header, not a cors request"
+		return d
+	}
+	if !corsContains(queryList(r, "origins", "*"), d.Origin) {
+		d.Allowed, d.Reason = false, fmt.Sprintf("origin %s not allowed", d.Origin)
+		return d
+	}
+	if !preflight {
+		return d
+	}
+	m := r.Header.Get("Access-Control-Request-Method")
+	if !corsContains(queryList(r, "methods", "GET,HEAD,POST"), m) {
+		d.Allowed, d.Reason = false, fmt.Sprintf("method %s not allowed", m)
+		return d
+	}
+	allowed := queryList(r, "headers", "Content-Type")
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); len(h) > 0 && !corsContains(allowed, h) {
+			d.Allowed, d.Reason = false, fmt.Sprintf("header %s not allowed", h)
+			return d
+		}
+	}
+	return d
+}
+
+// cors simulates a CORS enabled resource. Preflight OPTIONS requests are answered with 204, other methods with
+// the decision as JSON. The decision is also sent in X-Mse6-Cors-Decision. fault=missing omits the allow headers,
+// fault=wildcard sends a wildcard origin with credentials, fault=reflect allows and echoes any
+// origin and fault=mismatch sends a different origin.
+func cors(w http.ResponseWriter, r *http.Request) {
+	preflight := r.Method == "OPTIONS" && len(r.Header.Get("Access-Control-Request-Method")) > 0
+	d := corsDecide(r, preflight)
+	fault := r.URL.Query().Get("fault")
+	credentials := r.URL.Query().Get("credentials") == "true"
+
+	if fault == "reflect" && len(d.Origin) > 0 {
+		d.Allowed, d.Reason = true, "any origin reflected by fault"
+	}
+
+	h := w.Header()
+	h.Set("Server", "mse6 "+Version)
+	h.Set("Content-Encoding", "identity")
+	h.Set("X-Mse6-Cors-Decision", d.Reason)
+	h.Add("Vary", "Origin")
+	if d.Allowed && fault != "missing" {
+		origin := d.Origin
+		switch {
+		case fault == "wildcard":
+			origin = "*"
+			credentials = true
+		case fault == "mismatch":
+			origin = "https://mismatch.mse6.invalid"
+		case fault == "reflect":
+			origin = d.Origin
+		case corsContains(queryList(r, "origins", "*"), "*") && !credentials:
+			origin = "*"
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+		if credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", strings.Join(queryList(r, "methods", "GET,HEAD,POST"), ", "))
+			h.Set("Access-Control-Allow-Headers", strings.Join(queryList(r, "headers", "Content-Type"), ", "))
+			if len(r.URL.Query().Get("maxage")) > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(parseQueryInt(r, "maxage", 0)))
+			}
+		} else if expose := queryList(r, "expose", ""); len(expose) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(expose, ", "))
+		}
+	}
+
+	if preflight {
+		w.WriteHeader(204)
+	} else {
+		sendJSON(w, 200, d)
+	}
+	log.Info().Msgf("served %v %s request with X-Request-Id %s cors decision %q for origin %s", r.URL.Path, r.Method, getXRequestId(r), d.Reason, d.Origin)
+}
@@ -0,0 +1,68 @@
+package mse6
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCorsResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(cors))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		query       string
+		origin      string
+		reqMethod   string
+		reqHeaders  string
+		code        int
+		allowOrigin string
+		credentials string
+		decision    string
+	}{
+		{"preflight wildcard", "OPTIONS", "", "https://app.mse6", "POST", "Content-Type", 204, "*", "", "allowed"},
+		{"preflight allowlist", "OPTIONS", "?origins=https://app.mse6&credentials=true", "https://app.mse6", "GET", "", 204, "https://app.mse6", "true", "allowed"},
+		{"preflight origin denied", "OPTIONS", "?origins=https://app.mse6", "https://evil.mse6", "GET", "", 204, "", "", "origin https://evil.mse6 not allowed"},
+		{"preflight method denied", "OPTIONS", "?methods=GET", "https://app.mse6", "DELETE", "", 204, "", "", "method DELETE not allowed"},
+		{"preflight header denied", "OPTIONS", "", "https://app.mse6", "GET", "X-Custom", 204, "", "", "header X-Custom not allowed"},
+		{"simple", "GET", "?origins=https://app.mse6", "https://app.mse6", "", "", 200, "https://app.mse6", "", "allowed"},
+		{"fault missing", "GET", "?fault=missing", "https://app.mse6", "", "", 200, "", "", "allowed"},
+		{"fault wildcard", "GET", "?fault=wildcard", "https://app.mse6", "", "", 200, "*", "true", "allowed"},
+		{"fault reflect", "GET", "?origins=https://app.mse6&fault=reflect", "https://evil.mse6", "", "", 200, "https://evil.mse6", "", "any origin reflected by fault"},
+		{"fault reflect default origins", "GET", "?fault=reflect", "https://evil.mse6", "", "", 200, "https://evil.mse6", "", "any origin reflected by fault"},
+		{"fault mismatch", "GET", "?fault=mismatch", "https://app.mse6", "", "", 200, "https://mismatch.mse6.invalid", "", "allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.query, nil)
+			req.Header.Set("Origin", tt.origin)
+			if len(tt.reqMethod) > 0 {
+				req.Header.Set("Access-Control-Request-Method", tt.reqMethod)
+			}
+			if len(tt.reqHeaders) > 0 {
+				req.Header.Set("Access-Control-Request-Headers", tt.reqHeaders)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if res.Header.Get("Access-Control-Allow-Origin") != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin want %v, got %v", tt.allowOrigin, res.Header.Get("Access-Control-Allow-Origin"))
+			}
+			if res.Header.Get("Access-Control-Allow-Credentials") != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials want %v, got %v", tt.credentials, res.Header.Get("Access-Control-Allow-Credentials"))
+			}
+			if res.Header.Get("X-Mse6-Cors-Decision") != tt.decision {
+				t.Errorf("decision want %v, got %v", tt.decision, res.Header.Get("X-Mse6-Cors-Decision"))
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package mse6
+
+import (
+	"github.com/rs/zerolog/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+type decompressResult struct {
+	ContentEncoding string `json:"contentEncoding"`
+	EncodedSize     int    `json:"encodedSize"`
+	DecodedSize     int    `json:"decodedSize"`
+	SHA256          string `json:"sha256"`
+}
+
+// contentCodec maps a Content-Encoding to its registered codec name, x-gzip is an alias of gzip.
+func contentCodec(encoding string) (string, bool) {
+	if encoding == "x-gzip" {
+		encoding = "gzip"
+	}
+	_, ok := codecs.get(encoding)
+	return encoding, ok
+}
+
+// decompressMaxSize is the default and largest decoded body size, larger bodies are rejected with 413.
+const decompressMaxSize = 64 << 20
+
+// countingReader counts the bytes read from r and keeps the first read error other than io.EOF.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if err != nil && err != io.EOF && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// decompress decodes the request body according to its Content-Encoding and reports the encoded and decoded size
+// and the SHA-256 of the decoded body. Stacked encodings are decoded last to first while streaming, so the body is
+// never held in memory. Sends 415 for unsupported encodings, 400 for unreadable, corrupt or truncated bodies, 413 if
+// the decoded body or the data trailing the encoded stream exceeds max bytes and 422 if the decoded body doesn't
+// match the sha256 parameter.
+func decompress(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	max := int64(parseQueryInt(r, "max", decompressMaxSize))
+	if max < 1 || max > decompressMaxSize {
+		max = decompressMaxSize
+	}
+
+	encs := make([]string, 0)
+	for _, e := range strings.Split(strings.Join(r.Header["Content-Encoding"], ","), ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); len(e) > 0 && e != "identity" {
+			if _, ok := contentCodec(e); !ok {
+				w.Header().Set("Accept-Encoding", strings.Join(codecs.list(), ", "))
+				sendJSON(w, 415, map[string]string{"mse6": "415", "error": "unsupported Content-Encoding " + e})
+				log.Info().Msgf("served %v request with X-Request-Id %s code 415 unsupported Content-Encoding %s", r.URL.Path, getXRequestId(r), e)
+				return
+			}
+			encs = append(encs, e)
+		}
+	}
+
+	body := &countingReader{r: r.Body}
+	var dec io.Reader = body
+	var err error
+	for i := len(encs) - 1; i >= 0 && err == nil; i-- {
+		name, _ := contentCodec(encs[i])
+		codec, _ := codecs.get(name)
+		var rd io.ReadCloser
+		if rd, err = codec.Decode(dec); err == nil {
+			defer rd.Close()
+			dec = rd
+		}
+	}
+	var decoded int64
+	var digest string
+	if err == nil {
+		decoded, digest, err = hashCopy(&uploadLimitReader{r: dec, remaining: max})
+	}
+	if err == nil {
+		_, err = io.Copy(ioutil.Discard, &uploadLimitReader{r: body, remaining: max})
+	}
+	switch {
+	case body.err != nil:
+		sendJSON(w, 400, map[string]string{"mse6": "400", "error": "unable to read body: " + body.err.Error()})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 unable to read body, cause: %v", r.URL.Path, getXRequestId(r), body.err)
+		return
+	case err == errUploadTooLarge:
+		sendUploadTooLarge(w, r, max)
+		return
+	case err != nil:
+		ce := strings.Join(encs, ", ")
+		sendJSON(w, 400, map[string]string{"mse6": "400", "error": "unable to decode Content-Encoding " + ce + ": " + err.Error()})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 unable to decode Content-Encoding %s, cause: %v", r.URL.Path, getXRequestId(r), ce, err)
+		return
+	}
+	result := decompressResult{
+		ContentEncoding: strings.Join(encs, ", "),
+		EncodedSize:     int(body.n),
+		DecodedSize:     int(decoded),
+		SHA256:          digest,
+	}
+
+	code := 200
+	if want := r.URL.Query().Get("sha256"); len(want) > 0 && !strings.EqualFold(want, result.SHA256) {
+		code = 422
+	}
+	sendJSON(w, code, result)
+	log.Info().Msgf("served %v request with X-Request-Id %s code %d decoded %d bytes from %d bytes %s", r.URL.Path, getXRequestId(r), code, result.DecodedSize, result.EncodedSize, result.ContentEncoding)
+}
@@ -0,0 +1,79 @@
+package mse6
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecompressResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(decompress))
+	defer srv.Close()
+
+	plain := bytes.Repeat([]byte("mse6 compressed upload "), 100)
+	digest := sha256Hex(plain)
+	enc := func(name string, b []byte) []byte {
+		e, _ := encodeBytes(name, b)
+		return e
+	}
+
+	tests := []struct {
+		name  string
+		ce    string
+		body  []byte
+		query string
+		code  int
+	}{
+		{"identity", "", plain, "", 200},
+		{"gzip", "gzip", enc("gzip", plain), "", 200},
+		{"x-gzip", "x-gzip", enc("gzip", plain), "", 200},
+		{"deflate", "deflate", enc("deflate", plain), "", 200},
+		{"br", "br", enc("br", plain), "", 200},
+		{"zstd", "zstd", enc("zstd", plain), "", 200},
+		{"stacked", "gzip, br", enc("br", enc("gzip", plain)), "", 200},
+		{"digest match", "gzip", enc("gzip", plain), "?sha256=" + digest, 200},
+		{"digest mismatch", "gzip", enc("gzip", plain), "?sha256=00", 422},
+		{"unsupported", "compress", plain, "", 415},
+		{"truncated", "gzip", enc("gzip", plain)[:20], "", 400},
+		{"max", "gzip", enc("gzip", plain), "?max=100", 413},
+		{"trailing", "deflate", append(enc("deflate", plain), make([]byte, 200)...), "?max=100000", 200},
+		{"trailing exceeds max", "deflate", append(enc("deflate", plain), make([]byte, 20000)...), "?max=3000", 413},
+		{"bomb", "gzip, br", enc("br", enc("gzip", make([]byte, 4<<20))), "?max=1048576", 413},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", srv.URL+tt.query, bytes.NewReader(tt.body))
+			if len(tt.ce) > 0 {
+				req.Header.Set("Content-Encoding", tt.ce)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			var result decompressResult
+			json.NewDecoder(res.Body).Decode(&result)
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if (tt.code == 200 || tt.code == 422) && (result.DecodedSize != len(plain) || result.SHA256 != digest || result.EncodedSize != len(tt.body)) {
+				t.Errorf("want decoded size %d and digest %s, got %+v", len(plain), digest, result)
+			}
+		})
+	}
+}
+
+func TestDecompressIncompleteBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/decompress", iotest.TimeoutReader(strings.NewReader("partial upload")))
+	w := httptest.NewRecorder()
+	decompress(w, req)
+	if w.Code != 400 {
+		t.Errorf("response status code want 400, got %v: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,154 @@
+package mse6
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"github.com/rs/zerolog/log"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+type echoHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type echoBody struct {
+	Encoding string `json:"encoding"`
+	Data     string `json:"data"`
+	Size     int    `json:"size"`
+}
+
+type echoTLS struct {
+	Version            string `json:"version"`
+	CipherSuite        string `json:"cipherSuite"`
+	ServerName         string `json:"serverName"`
+	NegotiatedProtocol string `json:"negotiatedProtocol"`
+	DidResume          bool   `json:"didResume"`
+	PeerCertificates   int    `json:"peerCertificates"`
+}
+
+type echoTiming struct {
+	Received           string `json:"received"`
+	ReceivedUnixMillis int64  `json:"receivedUnixMillis"`
+	BodyReadMicros     int64  `json:"bodyReadMicros"`
+	HandlerMicros      int64  `json:"handlerMicros"`
+}
+
+type echoResponse struct {
+	Method           string              `json:"method"`
+	URL              string              `json:"url"`
+	Path             string              `json:"path"`
+	RawQuery         string              `json:"rawQuery"`
+	Query            map[string][]string `json:"query"`
+	Host             string              `json:"host"`
+	Headers          []echoHeader        `json:"headers"`
+	Body             echoBody            `json:"body"`
+	ContentLength    int64               `json:"contentLength"`
+	TransferEncoding []string            `json:"transferEncoding"`
+	Proto            string              `json:"proto"`
+	ProtoMajor       int                 `json:"protoMajor"`
+	ProtoMinor       int                 `json:"protoMinor"`
+	RemoteAddr       string              `json:"remoteAddr"`
+	TLS              *echoTLS            `json:"tls"`
+	Timing           echoTiming          `json:"timing"`
+}
+
+var tlsVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// echoHeaders flattens headers sorted by name. Duplicate headers keep the order in which they were received.
+// Host is not part of r.Header in net/http and is echoed separately.
+func echoHeaders(h http.Header) []echoHeader {
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	hs := make([]echoHeader, 0, len(h))
+	for _, k := range names {
+		for _, v := range h[k] {
+			hs = append(hs, echoHeader{Name: k, Value: v})
+		}
+	}
+	return hs
+}
+
+func newEchoBody(b []byte) echoBody {
+	if utf8.Valid(b) {
+		return echoBody{Encoding: "text", Data: string(b), Size: len(b)}
+	}
+	return echoBody{Encoding: "base64", Data: base64.StdEncoding.EncodeToString(b), Size: len(b)}
+}
+
+func newEchoTLS(cs *tls.ConnectionState) *echoTLS {
+	if cs == nil {
+		return nil
+	}
+	v, ok := tlsVersionNames[cs.Version]
+	if !ok {
+		v = "unknown"
+	}
+	return &echoTLS{
+		Version:            v,
+		CipherSuite:        tls.CipherSuiteName(cs.CipherSuite),
+		ServerName:         cs.ServerName,
+		NegotiatedProtocol: cs.NegotiatedProtocol,
+		DidResume:          cs.DidResume,
+		PeerCertificates:   len(cs.PeerCertificates),
+	}
+}
+
+// echo responds with a JSON description of the request for any method.
+func echo(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	bodyRead := time.Since(received)
+
+	te := r.TransferEncoding
+	if te == nil {
+		te = []string{}
+	}
+	e := echoResponse{
+		Method:           r.Method,
+		URL:              r.URL.String(),
+		Path:             r.URL.Path,
+		RawQuery:         r.URL.RawQuery,
+		Query:            r.URL.Query(),
+		Host:             r.Host,
+		Headers:          echoHeaders(r.Header),
+		Body:             newEchoBody(body),
+		ContentLength:    r.ContentLength,
+		TransferEncoding: te,
+		Proto:            r.Proto,
+		ProtoMajor:       r.ProtoMajor,
+		ProtoMinor:       r.ProtoMinor,
+		RemoteAddr:       r.RemoteAddr,
+		TLS:              newEchoTLS(r.TLS),
+		Timing: echoTiming{
+			Received:           received.UTC().Format(time.RFC3339Nano),
+			ReceivedUnixMillis: received.UnixNano() / int64(time.Millisecond),
+			BodyReadMicros:     int64(bodyRead / time.Microsecond),
+		},
+	}
+	e.Timing.HandlerMicros = int64(time.Since(received) / time.Microsecond)
+
+	if r.Method == "HEAD" {
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+	} else {
+		sendJSON(w, 200, e)
+	}
+	log.Info().Msgf("served %v %s echo request with X-Request-Id %s,%s reading %d bytes from inbound", r.URL.Path, r.Method, getXRequestId(r), expectContinue(r), len(body))
+}
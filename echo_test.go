@@ -0,0 +1,83 @@
+package mse6
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEchoResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(echo))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("PATCH", srv.URL+"/echo?a=1&a=2&b=x%20y", bytes.NewBuffer([]byte{0xff, 0xfe, 0x00}))
+	req.Header.Add("X-Dup", "first")
+	req.Header.Add("X-Dup", "second")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	var e echoResponse
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Errorf("echo response is not valid json, cause %v: %s", err, b)
+		return
+	}
+
+	if e.Method != "PATCH" {
+		t.Errorf("echo method want PATCH, got %v", e.Method)
+	}
+	if e.RawQuery != "a=1&a=2&b=x%20y" {
+		t.Errorf("echo raw query want a=1&a=2&b=x%%20y, got %v", e.RawQuery)
+	}
+	if len(e.Query["a"]) != 2 || e.Query["b"][0] != "x y" {
+		t.Errorf("echo decoded query wrong, got %v", e.Query)
+	}
+	if e.Body.Encoding != "base64" || e.Body.Data != "//4A" || e.Body.Size != 3 {
+		t.Errorf("echo binary body want base64 //4A, got %v", e.Body)
+	}
+	if e.ContentLength != 3 {
+		t.Errorf("echo content length want 3, got %v", e.ContentLength)
+	}
+	if e.TLS != nil {
+		t.Errorf("echo tls want null for plain http, got %v", e.TLS)
+	}
+
+	var dups []string
+	for _, h := range e.Headers {
+		if h.Name == "X-Dup" {
+			dups = append(dups, h.Value)
+		}
+	}
+	if len(dups) != 2 || dups[0] != "first" || dups[1] != "second" {
+		t.Errorf("echo duplicate headers want [first second], got %v", dups)
+	}
+}
+
+func TestEchoRespondsWithTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(echo))
+	defer srv.Close()
+
+	res, err := srv.Client().Post(srv.URL, "text/plain", bytes.NewBuffer([]byte("hello")))
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	var e echoResponse
+	json.Unmarshal(b, &e)
+	if e.TLS == nil || len(e.TLS.Version) == 0 || len(e.TLS.CipherSuite) == 0 {
+		t.Errorf("echo tls state missing, got %v", e.TLS)
+	}
+	if e.Body.Encoding != "text" || e.Body.Data != "hello" {
+		t.Errorf("echo text body want hello, got %v", e.Body)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"github.com/klauspost/compress/flate"
 	"io"
-	"io/ioutil"
 	"sync"
 )
 
@@ -12,45 +11,49 @@ const flateLevel int = 1
 
 var flateEmpty = []byte{0}
 
-var deflatePool = sync.Pool{
-	New: func() interface{} {
-		var buf bytes.Buffer
-		w, _ := flate.NewWriter(&buf, flateLevel)
-		return w
-	},
+type deflateCodec struct {
+	deflatePool sync.Pool
+	inflatePool sync.Pool
 }
 
-var inflatePool = sync.Pool{
-	New: func() interface{} {
-		buf := bytes.NewBuffer(flateEmpty)
-		r := flate.NewReader(buf)
-		return r
-	},
+func newDeflateCodec() *deflateCodec {
+	return &deflateCodec{
+		deflatePool: sync.Pool{
+			New: func() interface{} {
+				w, _ := flate.NewWriter(nil, flateLevel)
+				return w
+			},
+		},
+		inflatePool: sync.Pool{
+			New: func() interface{} {
+				return flate.NewReader(bytes.NewBuffer(flateEmpty))
+			},
+		},
+	}
 }
 
-//Flate compress a []byte
-func Deflate(input []byte) *[]byte {
-	wrt, _ := deflatePool.Get().(*flate.Writer)
-	buf := &bytes.Buffer{}
-	wrt.Reset(buf)
-
-	_, _ = wrt.Write(input)
-	_ = wrt.Close()
-	defer deflatePool.Put(wrt)
+func (c *deflateCodec) Name() string {
+	return "deflate"
+}
 
-	enc := buf.Bytes()
-	return &enc
+func (c *deflateCodec) Encode(w io.Writer) (io.WriteCloser, error) {
+	return newPooledWriter(&c.deflatePool, w), nil
 }
 
-// Inflate a []byte
-func Inflate(input []byte) *[]byte {
-	rd, _ := inflatePool.Get().(io.ReadCloser)
-	buf := bytes.NewBuffer(input)
-	_ = rd.(flate.Resetter).Reset(buf, nil)
+func (c *deflateCodec) Decode(r io.Reader) (io.ReadCloser, error) {
+	rd := c.inflatePool.Get().(io.ReadCloser)
+	if err := rd.(flate.Resetter).Reset(r, nil); err != nil {
+		return nil, err
+	}
+	return &pooledReader{dec: rd, item: rd, pool: &c.inflatePool}, nil
+}
 
-	dec, _ := ioutil.ReadAll(rd)
-	_ = rd.Close()
-	defer inflatePool.Put(rd)
+//Flate compress a []byte
+func Deflate(input []byte) ([]byte, error) {
+	return encodeBytes("deflate", input)
+}
 
-	return &dec
+// Inflate a []byte
+func Inflate(input []byte) ([]byte, error) {
+	return decodeBytes("deflate", input)
 }
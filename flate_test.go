@@ -4,8 +4,16 @@ import "testing"
 
 func TestDeflateAndReInflate(t *testing.T) {
 	const want = "MaryHadALittleLamb"
-	got := Inflate(*Deflate([]byte(want)))
-	if string(*got) != want {
-		t.Errorf("not reinflated. want %v, got %v", want, string(*got))
+	enc, _ := Deflate([]byte(want))
+	got, err := Inflate(enc)
+	if err != nil || string(got) != want {
+		t.Errorf("not reinflated. want %v, got %v cause %v", want, string(got), err)
+	}
+}
+
+func TestInflateTruncated(t *testing.T) {
+	enc, _ := Deflate([]byte("MaryHadALittleLamb"))
+	if _, err := Inflate(enc[:len(enc)/2]); err == nil {
+		t.Errorf("want error for truncated input")
 	}
 }
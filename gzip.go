@@ -1,29 +1,52 @@
 package mse6
 
 import (
-	"bytes"
 	"compress/gzip"
 	"github.com/rs/zerolog/log"
+	"io"
 	"sync"
 )
 
 var zipPool = sync.Pool{
 	New: func() interface{} {
-		var buf bytes.Buffer
-		return gzip.NewWriter(&buf)
+		return gzip.NewWriter(nil)
 	},
 }
 
-func gzipenc(input []byte) []byte {
-	wrt, _ := zipPool.Get().(*gzip.Writer)
-	buf := &bytes.Buffer{}
-	wrt.Reset(buf)
+// unzipPool is empty until the first reader is returned, gzip.NewReader needs a valid header.
+var unzipPool = sync.Pool{}
 
-	_, _ = wrt.Write(input)
-	_ = wrt.Close()
-	defer zipPool.Put(wrt)
+type gzipCodec struct{}
 
-	enc := buf.Bytes()
+func (c *gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c *gzipCodec) Encode(w io.Writer) (io.WriteCloser, error) {
+	return newPooledWriter(&zipPool, w), nil
+}
+
+func (c *gzipCodec) Decode(r io.Reader) (io.ReadCloser, error) {
+	rd, ok := unzipPool.Get().(*gzip.Reader)
+	if ok {
+		if err := rd.Reset(r); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if rd, err = gzip.NewReader(r); err != nil {
+			return nil, err
+		}
+	}
+	return &pooledReader{dec: rd, item: rd, pool: &unzipPool}, nil
+}
+
+func gzipenc(input []byte) ([]byte, error) {
+	enc, err := encodeBytes("gzip", input)
 	log.Trace().Msgf("zipped byte buffer size %d", len(enc))
-	return enc
+	return enc, err
+}
+
+func gzipdec(input []byte) ([]byte, error) {
+	return decodeBytes("gzip", input)
 }
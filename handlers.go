@@ -176,7 +176,7 @@ func patch(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func delete(w http.ResponseWriter, r *http.Request) {
+func deletef(w http.ResponseWriter, r *http.Request) {
 	body, _ := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
 	if r.Method == "DELETE" {
@@ -325,6 +325,34 @@ func parseWaitDuration(r *http.Request) time.Duration {
 	return wd
 }
 
+// queryList splits the comma separated parameter key, or def if absent, into its non empty trimmed values.
+func queryList(r *http.Request, key string, def string) []string {
+	v := r.URL.Query().Get(key)
+	if len(v) == 0 {
+		v = def
+	}
+	l := make([]string, 0)
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			l = append(l, s)
+		}
+	}
+	return l
+}
+
+func parseQueryInt(r *http.Request, key string, def int) int {
+	v := def
+	if len(r.URL.Query()[key]) > 0 {
+		i, err := strconv.Atoi(r.URL.Query()[key][0])
+		if err == nil {
+			v = i
+		} else {
+			log.Warn().Msgf("unable to parse %s parameter, using default %d", key, def)
+		}
+	}
+	return v
+}
+
 func badcontentlength(w http.ResponseWriter, r *http.Request) {
 	hj, _ := w.(http.Hijacker)
 	conn, bufrw, _ := hj.Hijack()
@@ -373,28 +401,25 @@ func tinyidentityf(w http.ResponseWriter, r *http.Request) {
 }
 
 func tinygzipf(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Server", "mse6 "+Version)
-	w.Header().Set("Content-Encoding", "gzip")
-	w.WriteHeader(200)
-	w.Write(gzipenc([]byte(`{}`)))
+	sendEncoded(w, r, "gzip", []byte(`{}`))
 
 	log.Info().Msgf("served %v tiny gzip request with X-Request-Id %s", r.URL.Path, getXRequestId(r))
 }
 
 func gzipf(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Server", "mse6 "+Version)
-	w.Header().Set("Content-Encoding", "gzip")
-	w.WriteHeader(200)
-	w.Write(gzipenc([]byte(`{"mse6":"Hello from the gzip endpoint"}`)))
+	sendEncoded(w, r, "gzip", []byte(`{"mse6":"Hello from the gzip endpoint"}`))
 
 	log.Info().Msgf("served %v request with X-Request-Id %s", r.URL.Path, getXRequestId(r))
 }
 
 func brotlif(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Server", "mse6 "+Version)
-	w.Header().Set("Content-Encoding", "br")
-	w.WriteHeader(200)
-	w.Write(*BrotliEncode([]byte(`{"mse6":"Hello from the brotli endpoint"}`)))
+	sendEncoded(w, r, "br", []byte(`{"mse6":"Hello from the brotli endpoint"}`))
+
+	log.Info().Msgf("served %v request with X-Request-Id %s", r.URL.Path, getXRequestId(r))
+}
+
+func zstdf(w http.ResponseWriter, r *http.Request) {
+	sendEncoded(w, r, "zstd", []byte(`{"mse6":"Hello from the zstd endpoint"}`))
 
 	log.Info().Msgf("served %v request with X-Request-Id %s", r.URL.Path, getXRequestId(r))
 }
@@ -402,31 +427,41 @@ func brotlif(w http.ResponseWriter, r *http.Request) {
 func chooseaef(w http.ResponseWriter, r *http.Request) {
 	ae := r.Header.Get("Accept-Encoding")
 	log.Info().Msgf("incoming %v request with Accept-Encoding %s and X-Request-Id %s", r.URL.Path, ae, getXRequestId(r))
-	if strings.Contains(ae, "br") {
+	w.Header().Set("Vary", "Accept-Encoding")
+	enc, ok := negotiateEncoding(r.Header["Accept-Encoding"], negotiateEncodings)
+	if !ok {
+		send406(w, r, "Accept-Encoding")
+		return
+	}
+	switch enc {
+	case "br":
 		brotlif(w, r)
-	} else if strings.Contains(ae, "gzip") {
+	case "zstd":
+		zstdf(w, r)
+	case "gzip":
 		gzipf(w, r)
-	} else if strings.Contains(ae, "deflate") {
+	case "deflate":
 		deflatef(w, r)
-	} else {
+	default:
 		get(w, r)
 	}
 }
 
 func deflatef(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Server", "mse6 "+Version)
-	w.Header().Set("Content-Encoding", "deflate")
-	w.WriteHeader(200)
-	w.Write(*Deflate([]byte(`{"mse6":"Hello from the deflate endpoint"}`)))
+	sendEncoded(w, r, "deflate", []byte(`{"mse6":"Hello from the deflate endpoint"}`))
 
 	log.Info().Msgf("served %v request with X-Request-Id %s", r.URL.Path, getXRequestId(r))
 }
 
 func badgzipf(w http.ResponseWriter, r *http.Request) {
+	gzipBytes, err := gzipenc([]byte(`{"mse6":"Hello from the gzip endpoint"}`))
+	if err != nil {
+		sendEncodingError(w, r, "gzip", err)
+		return
+	}
 	w.Header().Set("Server", "mse6 "+Version)
 	w.Header().Set("Content-Encoding", "gzip")
 	w.WriteHeader(200)
-	gzipBytes := gzipenc([]byte(`{"mse6":"Hello from the gzip endpoint"}`))
 	badBytes := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 0, 0}
 	copy(gzipBytes, badBytes)
 	w.Write(gzipBytes)
@@ -563,13 +598,17 @@ func jwks(w http.ResponseWriter, r *http.Request) {
 	log.Info().Msgf("served %v jwks request with X-Request-Id %s code %d", r.URL.Path, getXRequestId(r), 200)
 }
 
-func jwksrotate(w http.ResponseWriter, r *http.Request) {
+// jwksrotate alternates between two Jwks keys on every request, starting with k1. Reset to k1 with rc=0.
+func (s *server) jwksrotate(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Server", "mse6 "+Version)
 	w.Header().Set("Content-Encoding", "identity")
 	w.WriteHeader(200)
 
-	now := time.Now().Unix()
-	if now%2 == 0 {
+	if r.URL.Query().Get("rc") == "0" {
+		s.state.counters.reset("jwksrotate")
+	}
+	rc := s.state.counters.next("jwksrotate|global") + 1
+	if rc%2 == 1 {
 		w.Write([]byte(`{
   "keys": [
     {
@@ -595,10 +634,10 @@ func jwksrotate(w http.ResponseWriter, r *http.Request) {
 }` + "\n"))
 	}
 
-	log.Info().Msgf("served %v rotating jwks request with X-Request-Id %s code %d", r.URL.Path, getXRequestId(r), 200)
+	log.Info().Msgf("served %v rotating jwks request count %d with X-Request-Id %s code %d", r.URL.Path, rc, getXRequestId(r), 200)
 }
 
-func jwksbadrotate(w http.ResponseWriter, r *http.Request) {
+func (s *server) jwksbadrotate(w http.ResponseWriter, r *http.Request) {
 	k1 := `{
   "keys": [
     {
@@ -657,10 +696,10 @@ func jwksbadrotate(w http.ResponseWriter, r *http.Request) {
 	if len(r.URL.Query()["rc"]) > 0 {
 		c, _ := strconv.Atoi(r.URL.Query()["rc"][0])
 		if c == 0 {
-			rc = 0
+			s.state.counters.reset("jwksbadrotate")
 		}
 	}
-	rc++
+	rc := s.state.counters.next("jwksbadrotate|global") + 1
 
 	if rc == 1 {
 		w.Write([]byte(k1))
@@ -17,7 +17,7 @@ func TestHandlers(t *testing.T) {
 		responseBodyError bool
 		responseCode      int
 	}{
-		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/", Handler: index}, false, false, 200},
+		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/", Handler: newServer().index}, false, false, 200},
 
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/badcontentlength", Handler: badcontentlength}, false, true, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/badgzip", Handler: badgzipf}, false, true, 200},
@@ -25,8 +25,12 @@ func TestHandlers(t *testing.T) {
 		{ServerHandler{Methods: []string{"CONNECT"}, Pattern: Prefix + "/connect", Handler: connect}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/choose", Handler: chooseaef}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/chunked", Handler: chunked}, false, false, 200},
-		{ServerHandler{Methods: []string{"DELETE"}, Pattern: Prefix + "/delete", Handler: delete}, false, false, 204},
+		{ServerHandler{Methods: []string{"POST", "PUT"}, Pattern: Prefix + "/continue", Handler: continuef}, false, false, 200},
+		{ServerHandler{Methods: []string{"POST", "PUT"}, Pattern: Prefix + "/continuefinal", Handler: continuefinal}, false, false, 200},
+		{ServerHandler{Methods: []string{"POST", "PUT"}, Pattern: Prefix + "/continuerefuse", Handler: continuerefuse}, false, false, 417},
+		{ServerHandler{Methods: []string{"DELETE"}, Pattern: Prefix + "/delete", Handler: deletef}, false, false, 204},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/deflate", Handler: deflatef}, false, false, 200},
+		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/earlyhints", Handler: earlyhints}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/echoheader", Handler: echoheader}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/echoquery", Handler: echoquery}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/echoport", Handler: echoport}, false, false, 200},
@@ -38,12 +42,13 @@ func TestHandlers(t *testing.T) {
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/hangupduringheader", Handler: hangupConnDuringHeadersSend}, false, true, 0},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/hangupafterheader", Handler: hangupConnAfterHeadersSent}, false, true, 0},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/hangupduringbody", Handler: hangupConnDuringBodySend}, false, true, 0},
+		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/informational", Handler: informational}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwks", Handler: jwks}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwksbad", Handler: jwksbad}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwksmix", Handler: jwksmix}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwkses256", Handler: jwkses256}, false, false, 200},
-		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwksrotate", Handler: jwksrotate}, false, false, 200},
-		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwksbadrotate", Handler: jwksbadrotate}, false, false, 200},
+		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwksrotate", Handler: newServer().jwksrotate}, false, false, 200},
+		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/jwksbadrotate", Handler: newServer().jwksbadrotate}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/nocontentenc", Handler: nocontentenc}, false, false, 200},
 		{ServerHandler{Methods: []string{"OPTIONS"}, Pattern: Prefix + "/options", Handler: options}, false, false, 200},
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/options?code=204", Handler: options}, false, false, 405},
@@ -59,6 +64,7 @@ func TestHandlers(t *testing.T) {
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/unknowncontentenc", Handler: unknowncontentenc}, false, false, 200},
 		//bad websocket upgrade
 		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/websocket", Handler: websocket}, false, false, 400},
+		{ServerHandler{Methods: []string{"GET"}, Pattern: Prefix + "/zstd", Handler: zstdf}, false, false, 200},
 	}
 
 	for _, tt := range tests {
@@ -199,7 +205,7 @@ func TestPatchResponds(t *testing.T) {
 }
 
 func TestDeleteResponds(t *testing.T) {
-	srv := httptest.NewServer(http.HandlerFunc(delete))
+	srv := httptest.NewServer(http.HandlerFunc(deletef))
 	defer srv.Close()
 
 	jsonData := map[string]string{"scandi": "grind", "convex": "grind", "concave": "grind"}
@@ -0,0 +1,145 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const earlyHintsStatus = 103
+
+// informationalMaxResponses caps the interim responses per request, larger n are rejected with 400.
+const informationalMaxResponses = 100
+
+// continuef reads the request body which makes net/http send 100 Continue if the client asked for it.
+func continuef(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		body, _ := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(200)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"Hello from the continue endpoint", "bytesRead":"%d"}`, len(body))))
+		log.Info().Msgf("served %v request with X-Request-Id %s,%s reading %d bytes from inbound", r.URL.Path, getXRequestId(r), expectContinue(r), len(body))
+	} else {
+		send405(w, r)
+	}
+}
+
+// continuedelay waits before reading the request body, which delays the 100 Continue response.
+func continuedelay(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		wd := parseWaitDuration(r)
+		time.Sleep(wd)
+		body, _ := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(200)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"Hello from the continuedelay endpoint", "bytesRead":"%d", "waitSeconds":"%d"}`, len(body), int(wd.Seconds()))))
+		log.Info().Msgf("served %v request with X-Request-Id %s,%s reading %d bytes from inbound after %d seconds", r.URL.Path, getXRequestId(r), expectContinue(r), len(body), int(wd.Seconds()))
+	} else {
+		send405(w, r)
+	}
+}
+
+// continuerefuse sends 417 Expectation Failed without reading the request body.
+func continuerefuse(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(417)
+		w.Write([]byte(`{"mse6":"417"}`))
+		log.Info().Msgf("served %v request with X-Request-Id %s,%s code 417", r.URL.Path, getXRequestId(r), expectContinue(r))
+	} else {
+		send405(w, r)
+	}
+}
+
+// continuefinal sends a final status code without reading the request body, so no 100 Continue is ever sent.
+func continuefinal(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		code := parseQueryInt(r, "code", 200)
+		if !(code > 199 && code < 1000) {
+			code = 200
+		}
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(code)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"%d"}`, code)))
+		log.Info().Msgf("served %v request with X-Request-Id %s,%s code %d without reading inbound", r.URL.Path, getXRequestId(r), expectContinue(r), code)
+	} else {
+		send405(w, r)
+	}
+}
+
+func earlyhints(w http.ResponseWriter, r *http.Request) {
+	sendInformational(w, r, earlyHintsStatus)
+}
+
+func informational(w http.ResponseWriter, r *http.Request) {
+	code := parseQueryInt(r, "code", earlyHintsStatus)
+	if !(code > 99 && code < 200) || code == 101 {
+		code = earlyHintsStatus
+	}
+	sendInformational(w, r, code)
+}
+
+// sendInformational writes n interim responses with the given 1xx code before the final 200 response.
+// The connection is hijacked so interim responses are sent regardless of the Go runtime version.
+func sendInformational(w http.ResponseWriter, r *http.Request, code int) {
+	n := parseQueryInt(r, "n", 1)
+	if n > informationalMaxResponses {
+		sendJSON(w, 400, map[string]interface{}{"mse6": "400", "error": "n exceeds limit", "max": informationalMaxResponses})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 n %d exceeds %d informational responses", r.URL.Path, getXRequestId(r), n, informationalMaxResponses)
+		return
+	}
+	var wd time.Duration
+	if len(r.URL.Query()["wait"]) > 0 {
+		wd = parseWaitDuration(r)
+	}
+
+	hj, _ := w.(http.Hijacker)
+	conn, bufrw, _ := hj.Hijack()
+	defer conn.Close()
+
+	for i := 0; i < n; i++ {
+		bufrw.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", code, informationalText(code)))
+		if code == earlyHintsStatus {
+			bufrw.WriteString(fmt.Sprintf("Link: <%sstyle%d.css>; rel=preload; as=style\r\n", Prefix, i+1))
+		}
+		bufrw.WriteString("\r\n")
+		bufrw.Flush()
+		if wd > 0 {
+			time.Sleep(wd)
+		}
+	}
+
+	b := fmt.Sprintf(`{"mse6":"Hello from the informational endpoint", "informational":"%d", "count":"%d"}`, code, n)
+	bufrw.WriteString("HTTP/1.1 200 OK\r\n")
+	bufrw.WriteString(fmt.Sprintf("Server: mse6 %s\r\n", Version))
+	bufrw.WriteString("Content-Encoding: identity\r\n")
+	bufrw.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(b)))
+	bufrw.WriteString("Connection: close\r\n")
+	bufrw.WriteString("\r\n")
+	bufrw.WriteString(b)
+	bufrw.Flush()
+
+	log.Info().Msgf("served %v request with X-Request-Id %s after %d informational %d responses", r.URL.Path, getXRequestId(r), n, code)
+}
+
+func informationalText(code int) string {
+	switch code {
+	case 102:
+		return "Processing"
+	case earlyHintsStatus:
+		return "Early Hints"
+	}
+	t := http.StatusText(code)
+	if t == "" {
+		t = "Informational"
+	}
+	return t
+}
@@ -0,0 +1,107 @@
+package mse6
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestContinueRefuseResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(continuerefuse))
+	defer srv.Close()
+
+	client := http.Client{Transport: &http.Transport{ExpectContinueTimeout: time.Second * 5}}
+	req, _ := http.NewRequest("POST", srv.URL, bytes.NewBuffer([]byte(`{"hello":"world"}`)))
+	req.Header.Set("Expect", "100-continue")
+
+	got100 := false
+	trace := &httptrace.ClientTrace{Got100Continue: func() { got100 = true }}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 417 {
+		t.Errorf("response status code want 417, got %v", res.StatusCode)
+	}
+	if got100 {
+		t.Errorf("server sent 100 Continue, should have refused")
+	}
+}
+
+func TestContinueResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(continuef))
+	defer srv.Close()
+
+	client := http.Client{Transport: &http.Transport{ExpectContinueTimeout: time.Second * 5}}
+	req, _ := http.NewRequest("PUT", srv.URL, bytes.NewBuffer([]byte(`{"hello":"world"}`)))
+	req.Header.Set("Expect", "100-continue")
+
+	got100 := false
+	trace := &httptrace.ClientTrace{Got100Continue: func() { got100 = true }}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Errorf("response status code want 200, got %v", res.StatusCode)
+	}
+	if !got100 {
+		t.Errorf("server did not send 100 Continue")
+	}
+}
+
+func TestEarlyHintsResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(earlyhints))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"?n=2", nil)
+	hints := 0
+	trace := &httptrace.ClientTrace{Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+		if code == 103 && len(header.Get("Link")) > 0 {
+			hints++
+		}
+		return nil
+	}}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	_, err2 := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err2 != nil {
+		t.Errorf("body parsing did not return ok cause %v", err2)
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("response status code want 200, got %v", res.StatusCode)
+	}
+	if hints != 2 {
+		t.Errorf("want 2 early hints, got %v", hints)
+	}
+}
+
+func TestInformationalCapsResponses(t *testing.T) {
+	w := httptest.NewRecorder()
+	earlyhints(w, httptest.NewRequest("GET", "/earlyhints?n=1000000000", nil))
+	if w.Code != 400 {
+		t.Errorf("response status code want 400, got %v", w.Code)
+	}
+}
@@ -0,0 +1,279 @@
+package mse6
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type jsonPointerError struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+type jsonErrors struct {
+	Errors []jsonPointerError `json:"errors"`
+}
+
+// jsonPointer appends a reference token to a RFC 6901 JSON pointer.
+func jsonPointer(ptr string, token string) string {
+	return ptr + "/" + strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch n := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if f, err := n.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+func jsonTypeMatches(want string, v interface{}) bool {
+	got := jsonTypeOf(v)
+	return got == want || (want == "number" && got == "integer")
+}
+
+func schemaFloat(s map[string]interface{}, key string) (float64, bool) {
+	if n, ok := s[key].(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// validateJSONSchema checks v against the subset of JSON Schema keywords mse6 supports: type, enum,
+// properties, required, additionalProperties, items, minimum, maximum, minLength, maxLength and pattern.
+func validateJSONSchema(s map[string]interface{}, v interface{}, ptr string) []jsonPointerError {
+	errs := make([]jsonPointerError, 0)
+	fail := func(format string, a ...interface{}) {
+		errs = append(errs, jsonPointerError{Pointer: ptr, Message: fmt.Sprintf(format, a...)})
+	}
+
+	switch t := s["type"].(type) {
+	case string:
+		if !jsonTypeMatches(t, v) {
+			fail("expected type %s, got %s", t, jsonTypeOf(v))
+			return errs
+		}
+	case []interface{}:
+		matched := false
+		for _, tt := range t {
+			if ts, ok := tt.(string); ok && jsonTypeMatches(ts, v) {
+				matched = true
+			}
+		}
+		if !matched {
+			fail("expected one of types %v, got %s", t, jsonTypeOf(v))
+			return errs
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		matched := false
+		vb, _ := json.Marshal(v)
+		for _, e := range enum {
+			eb, _ := json.Marshal(e)
+			if bytes.Equal(vb, eb) {
+				matched = true
+			}
+		}
+		if !matched {
+			fail("value %s is not one of the enumerated values", vb)
+		}
+	}
+
+	switch val := v.(type) {
+	case json.Number:
+		f, _ := val.Float64()
+		if min, ok := schemaFloat(s, "minimum"); ok && f < min {
+			fail("value %v is less than minimum %v", val, min)
+		}
+		if max, ok := schemaFloat(s, "maximum"); ok && f > max {
+			fail("value %v is greater than maximum %v", val, max)
+		}
+	case string:
+		l := float64(len([]rune(val)))
+		if min, ok := schemaFloat(s, "minLength"); ok && l < min {
+			fail("length %v is less than minLength %v", l, min)
+		}
+		if max, ok := schemaFloat(s, "maxLength"); ok && l > max {
+			fail("length %v is greater than maxLength %v", l, max)
+		}
+		if p, ok := s["pattern"].(string); ok {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				fail("schema pattern %q is invalid: %v", p, err)
+			} else if !re.MatchString(val) {
+				fail("value %q does not match pattern %q", val, p)
+			}
+		}
+	case []interface{}:
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				errs = append(errs, validateJSONSchema(items, item, jsonPointer(ptr, fmt.Sprint(i)))...)
+			}
+		}
+	case map[string]interface{}:
+		if req, ok := s["required"].([]interface{}); ok {
+			for _, k := range req {
+				if ks, ok := k.(string); ok {
+					if _, present := val[ks]; !present {
+						errs = append(errs, jsonPointerError{Pointer: jsonPointer(ptr, ks), Message: "required property is missing"})
+					}
+				}
+			}
+		}
+		props, _ := s["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ps, ok := props[k].(map[string]interface{}); ok {
+				errs = append(errs, validateJSONSchema(ps, val[k], jsonPointer(ptr, k))...)
+				continue
+			}
+			switch ap := s["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					errs = append(errs, jsonPointerError{Pointer: jsonPointer(ptr, k), Message: "additional property is not allowed"})
+				}
+			case map[string]interface{}:
+				errs = append(errs, validateJSONSchema(ap, val[k], jsonPointer(ptr, k))...)
+			}
+		}
+	}
+	return errs
+}
+
+// jsonNumber matches the number grammar of RFC 8259. json.Number.Float64 also accepts +1, NaN or Inf,
+// which can't be marshalled.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// alterJSONTypes turns numbers and booleans into strings and strings holding numbers into numbers.
+func alterJSONTypes(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		return val.String()
+	case bool:
+		return fmt.Sprint(val)
+	case string:
+		if jsonNumber.MatchString(val) {
+			return json.Number(val)
+		}
+		return val
+	case []interface{}:
+		for i := range val {
+			val[i] = alterJSONTypes(val[i])
+		}
+	case map[string]interface{}:
+		for k := range val {
+			val[k] = alterJSONTypes(val[k])
+		}
+	}
+	return v
+}
+
+// addUnknownJSONFields adds a field to every object that no client model knows about.
+func addUnknownJSONFields(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		for i := range val {
+			val[i] = addUnknownJSONFields(val[i])
+		}
+	case map[string]interface{}:
+		for k := range val {
+			val[k] = addUnknownJSONFields(val[k])
+		}
+		val["mse6Unknown"] = map[string]interface{}{"mse6": "unknown field"}
+	}
+	return v
+}
+
+// jsonecho parses the JSON request body and echoes it back. A JSON Schema in the schema parameter or
+// X-Mse6-Schema header is validated against and violations are reported as 422 with JSON pointers.
+// fault=types|unknown|truncate alters the echo.
+func jsonecho(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	v, err := decodeJSON(body)
+	if err != nil {
+		sendJSON(w, 400, jsonErrors{Errors: []jsonPointerError{{Pointer: "", Message: "request body is not valid JSON: " + err.Error()}}})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 invalid JSON body", r.URL.Path, getXRequestId(r))
+		return
+	}
+
+	s := r.URL.Query().Get("schema")
+	if len(s) == 0 {
+		s = r.Header.Get("X-Mse6-Schema")
+	}
+	if len(s) > 0 {
+		sv, err := decodeJSON([]byte(s))
+		schema, ok := sv.(map[string]interface{})
+		if err != nil || !ok {
+			sendJSON(w, 400, jsonErrors{Errors: []jsonPointerError{{Pointer: "", Message: "schema is not a valid JSON object"}}})
+			log.Info().Msgf("served %v request with X-Request-Id %s code 400 invalid schema", r.URL.Path, getXRequestId(r))
+			return
+		}
+		if errs := validateJSONSchema(schema, v, ""); len(errs) > 0 {
+			sendJSON(w, 422, jsonErrors{Errors: errs})
+			log.Info().Msgf("served %v request with X-Request-Id %s code 422 with %d schema violations", r.URL.Path, getXRequestId(r), len(errs))
+			return
+		}
+	}
+
+	fault := r.URL.Query().Get("fault")
+	switch fault {
+	case "types":
+		v = alterJSONTypes(v)
+	case "unknown":
+		v = addUnknownJSONFields(v)
+	}
+
+	if fault == "truncate" {
+		b, _ := json.Marshal(v)
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write(b[:len(b)/2])
+	} else {
+		sendJSON(w, 200, v)
+	}
+	log.Info().Msgf("served %v %s request with X-Request-Id %s code 200 echoing %d bytes of JSON, fault %q", r.URL.Path, r.Method, getXRequestId(r), len(body), fault)
+}
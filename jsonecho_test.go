@@ -0,0 +1,76 @@
+package mse6
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestJsonEchoResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(jsonecho))
+	defer srv.Close()
+
+	schema := `{"type":"object","required":["name","a/b"],"additionalProperties":false,"properties":{
+		"name":{"type":"string","minLength":2},
+		"age":{"type":"integer","minimum":0},
+		"tags":{"type":"array","items":{"enum":["x","y"]}}}}`
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+		code  int
+		want  []string
+	}{
+		{"echo", "", `{"name":"mse6","n":1.50}`, 200, []string{`"n": 1.50`}},
+		{"invalid json", "", `{"name":`, 400, []string{"not valid JSON"}},
+		{"schema", "?schema=" + url.QueryEscape(schema), `{"name":"m","age":-1.5,"tags":["x","z"],"extra":true}`, 422, []string{
+			`"/name"`, `"/age"`, `"/tags/1"`, `"/extra"`, `"/a~1b"`,
+		}},
+		{"types", "?fault=types", `{"n":1,"b":true,"s":"2"}`, 200, []string{`"n": "1"`, `"b": "true"`, `"s": 2`}},
+		{"types not json numbers", "?fault=types", `{"p":"+1","n":"NaN","e":"-1.5e3"}`, 200, []string{`"p": "+1"`, `"n": "NaN"`, `"e": -1.5e3`}},
+		{"unknown", "?fault=unknown", `{"o":{}}`, 200, []string{`"mse6Unknown"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Post(srv.URL+tt.query, "application/json", bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v: %s", tt.code, res.StatusCode, b)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(string(b), w) {
+					t.Errorf("want response containing %v, got %s", w, b)
+				}
+			}
+		})
+	}
+}
+
+func TestJsonEchoTruncates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(jsonecho))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"?fault=truncate", "application/json", bytes.NewBufferString(`{"name":"mse6","list":[1,2,3]}`))
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	var v interface{}
+	if json.Unmarshal(b, &v) == nil {
+		t.Errorf("want truncated JSON, got %s", b)
+	}
+}
@@ -0,0 +1,228 @@
+package mse6
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const jwksMaxAgeSeconds = 300
+
+// jwksMaxAdvance caps the steps of a single advance, every step can generate a RSA key.
+const jwksMaxAdvance = 12
+
+// rotationKey is a signing key published in the rotating JWKS.
+type rotationKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+// jwksRotation publishes RSA keys following an explicit schedule. Every advance moves one step along
+// the timeline: add the next key (published, not yet signing), activate it while the previous key
+// overlaps, then retire the previous key. Counting starts at stage 0 with a single active key.
+// Stages with a duration in the schedule advance by themselves once it has passed, stages without
+// one wait for a forced advance.
+type jwksRotation struct {
+	mu         sync.Mutex
+	generation int
+	stage      int
+	active     rotationKey
+	next       *rotationKey
+	previous   *rotationKey
+	schedule   [3]time.Duration
+	since      time.Time
+}
+
+const (
+	rotationStable = iota
+	rotationAdded
+	rotationOverlap
+)
+
+func newRotationKey(generation int) rotationKey {
+	return rotationKey{kid: fmt.Sprintf("rotate-k%d", generation), key: mustGenerateRSAKey()}
+}
+
+func (j *jwksRotation) init(now time.Time) {
+	if j.active.key == nil {
+		j.generation = 1
+		j.active = newRotationKey(j.generation)
+		j.since = now
+	}
+}
+
+// configure sets how long each stage lasts before it advances by itself. Zero waits for a forced advance.
+func (j *jwksRotation) configure(stable time.Duration, added time.Duration, overlap time.Duration, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.init(now)
+	j.schedule = [3]time.Duration{stable, added, overlap}
+	j.since = now
+}
+
+// tick advances all stages whose scheduled duration has passed. A schedule that fell behind by more than
+// a full rotation catches up with one rotation only, and restarts the current stage now.
+func (j *jwksRotation) tick(now time.Time) {
+	for i := 0; i < len(j.schedule); i++ {
+		d := j.schedule[j.stage]
+		if d <= 0 || now.Sub(j.since) < d {
+			return
+		}
+		j.step()
+		j.since = j.since.Add(d)
+	}
+	if d := j.schedule[j.stage]; d > 0 && now.Sub(j.since) >= d {
+		j.since = now
+	}
+}
+
+// advance force moves the rotation n steps along the timeline and returns the new stage.
+func (j *jwksRotation) advance(n int, now time.Time) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.init(now)
+	j.tick(now)
+	for i := 0; i < n; i++ {
+		j.step()
+		j.since = now
+	}
+	return j.stage
+}
+
+func (j *jwksRotation) step() {
+	switch j.stage {
+	case rotationStable:
+		j.generation++
+		k := newRotationKey(j.generation)
+		j.next = &k
+		j.stage = rotationAdded
+	case rotationAdded:
+		prev := j.active
+		j.previous = &prev
+		j.active = *j.next
+		j.next = nil
+		j.stage = rotationOverlap
+	case rotationOverlap:
+		j.previous = nil
+		j.stage = rotationStable
+	}
+}
+
+// published returns the keys currently in the JWKS, the active key and the stage.
+func (j *jwksRotation) published(now time.Time) ([]rotationKey, rotationKey, int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.init(now)
+	j.tick(now)
+
+	keys := []rotationKey{j.active}
+	if j.next != nil {
+		keys = append(keys, *j.next)
+	}
+	if j.previous != nil {
+		keys = append(keys, *j.previous)
+	}
+	return keys, j.active, j.stage
+}
+
+func rotationStageName(stage int) string {
+	switch stage {
+	case rotationAdded:
+		return "added"
+	case rotationOverlap:
+		return "overlap"
+	default:
+		return "stable"
+	}
+}
+
+// jwksschedule serves the JWKS of the rotation engine with configurable caching headers. A negative maxage is
+// rejected with 400.
+func (s *server) jwksschedule(w http.ResponseWriter, r *http.Request) {
+	maxAge := parseQueryInt(r, "maxage", jwksMaxAgeSeconds)
+	if maxAge < 0 {
+		sendJSON(w, 400, map[string]string{"mse6": "400", "error": "maxage must not be negative"})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 negative maxage %d", r.URL.Path, getXRequestId(r), maxAge)
+		return
+	}
+	keys, active, stage := s.state.rotation.published(time.Now())
+
+	jwks := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		jwks = append(jwks, rsaJWK(k.kid, &k.key.PublicKey))
+	}
+
+	if r.URL.Query().Get("nocache") == "true" {
+		w.Header().Set("Cache-Control", "no-cache, no-store")
+		w.Header().Set("Expires", "0")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+		w.Header().Set("Expires", time.Now().Add(time.Duration(maxAge)*time.Second).UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("X-Mse6-Jwks-Stage", rotationStageName(stage))
+	w.Header().Set("X-Mse6-Jwks-Active-Kid", active.kid)
+
+	sendJSON(w, 200, map[string]interface{}{"keys": jwks})
+	log.Info().Msgf("served %v jwks schedule request with X-Request-Id %s code %d stage %s active kid %s", r.URL.Path, getXRequestId(r), 200, rotationStageName(stage), active.kid)
+}
+
+// jwksadvance force advances the rotation timeline by n steps, at most jwksMaxAdvance. If any of stable, added or
+// overlap are present, it first replaces the schedule with those stage durations in seconds.
+func (s *server) jwksadvance(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	q := r.URL.Query()
+	if len(q.Get("stable")) > 0 || len(q.Get("added")) > 0 || len(q.Get("overlap")) > 0 {
+		s.state.rotation.configure(
+			time.Duration(parseQueryInt(r, "stable", 0))*time.Second,
+			time.Duration(parseQueryInt(r, "added", 0))*time.Second,
+			time.Duration(parseQueryInt(r, "overlap", 0))*time.Second,
+			now)
+	}
+	n := parseQueryInt(r, "n", 1)
+	if n < 0 {
+		n = 0
+	}
+	if n > jwksMaxAdvance {
+		n = jwksMaxAdvance
+	}
+	s.state.rotation.advance(n, now)
+	_, active, stage := s.state.rotation.published(now)
+
+	sendJSON(w, 200, map[string]string{
+		"mse6":      "Hello from the jwksadvance endpoint",
+		"stage":     rotationStageName(stage),
+		"activeKid": active.kid,
+	})
+	log.Info().Msgf("served %v jwks advance request with X-Request-Id %s code %d advanced %d steps to stage %s", r.URL.Path, getXRequestId(r), 200, n, rotationStageName(stage))
+}
+
+// jwkssign issues a token signed with the currently active key of the rotation engine.
+func (s *server) jwkssign(w http.ResponseWriter, r *http.Request) {
+	_, active, _ := s.state.rotation.published(time.Now())
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": oidcIssuer(r),
+		"sub": oidcClientId,
+		"aud": oidcAudience,
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(oidcTokenSeconds * time.Second).Unix(),
+	}
+	tok, err := signJWT("RS256", active.kid, active.key, claims)
+	if err != nil {
+		sendJSON(w, 500, map[string]string{"error": "server_error", "error_description": err.Error()})
+		log.Warn().Msgf("unable to sign jwks rotation token for X-Request-Id %s, cause: %s", getXRequestId(r), err)
+		return
+	}
+
+	sendJSON(w, 200, map[string]interface{}{
+		"access_token": tok,
+		"token_type":   "Bearer",
+		"expires_in":   oidcTokenSeconds,
+		"kid":          active.kid,
+	})
+	log.Info().Msgf("served %v jwks sign request with X-Request-Id %s code %d kid %s", r.URL.Path, getXRequestId(r), 200, active.kid)
+}
@@ -0,0 +1,157 @@
+package mse6
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJwksRotationSchedule(t *testing.T) {
+	s := newServer()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jwksschedule", s.jwksschedule)
+	mux.HandleFunc("/jwksadvance", s.jwksadvance)
+	mux.HandleFunc("/jwkssign", s.jwkssign)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	sign := func() string {
+		res, _ := http.Post(srv.URL+"/jwkssign", "application/json", nil)
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		var tr map[string]interface{}
+		json.Unmarshal(b, &tr)
+		return tr["access_token"].(string)
+	}
+
+	t1 := sign()
+	tests := []struct {
+		stage   string
+		keys    int
+		t1Valid bool
+	}{
+		{"added", 2, true},
+		{"overlap", 2, true},
+		{"stable", 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.stage, func(t *testing.T) {
+			res, _ := http.Post(srv.URL+"/jwksadvance", "application/json", nil)
+			res.Body.Close()
+
+			res, err := http.Get(srv.URL + "/jwksschedule?maxage=60")
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			var jwks map[string]interface{}
+			json.Unmarshal(b, &jwks)
+
+			if got := res.Header.Get("X-Mse6-Jwks-Stage"); got != tt.stage {
+				t.Errorf("rotation stage want %v, got %v", tt.stage, got)
+			}
+			if got := res.Header.Get("Cache-Control"); got != "public, max-age=60" {
+				t.Errorf("Cache-Control want public, max-age=60, got %v", got)
+			}
+			if got := len(jwks["keys"].([]interface{})); got != tt.keys {
+				t.Errorf("published keys want %v, got %v", tt.keys, got)
+			}
+			if _, valid := verifyJWT(t, t1, jwks); valid != tt.t1Valid {
+				t.Errorf("token signed before rotation valid want %v, got %v", tt.t1Valid, valid)
+			}
+			if _, valid := verifyJWT(t, sign(), jwks); !valid {
+				t.Errorf("token signed with active key does not validate against published keys")
+			}
+		})
+	}
+}
+
+func TestJwksRotationFollowsTimeline(t *testing.T) {
+	j := &jwksRotation{}
+	start := time.Now()
+	j.configure(time.Minute, 10*time.Second, 30*time.Second, start)
+
+	tests := []struct {
+		after time.Duration
+		stage string
+		keys  int
+	}{
+		{59 * time.Second, "stable", 1},
+		{60 * time.Second, "added", 2},
+		{70 * time.Second, "overlap", 2},
+		{100 * time.Second, "stable", 1},
+		{160 * time.Second, "added", 2},
+	}
+	for _, tt := range tests {
+		keys, _, stage := j.published(start.Add(tt.after))
+		if rotationStageName(stage) != tt.stage || len(keys) != tt.keys {
+			t.Errorf("after %v want stage %s with %d keys, got %s with %d keys", tt.after, tt.stage, tt.keys, rotationStageName(stage), len(keys))
+		}
+	}
+}
+
+func TestJwksAdvanceReportsStageAndCapsSteps(t *testing.T) {
+	s := newServer()
+	srv := httptest.NewServer(http.HandlerFunc(s.jwksadvance))
+	defer srv.Close()
+
+	advance := func(query string) map[string]string {
+		res, err := http.Post(srv.URL+query, "application/json", nil)
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return nil
+		}
+		var v map[string]string
+		json.NewDecoder(res.Body).Decode(&v)
+		res.Body.Close()
+		return v
+	}
+
+	advance("?n=1")
+	if v := advance("?n=0"); v["stage"] != "added" {
+		t.Errorf("want current stage added for n=0, got %v", v["stage"])
+	}
+	if v := advance("?n=100000"); v["activeKid"] != "rotate-k5" {
+		t.Errorf("want advance capped at %d steps to rotate-k5, got %v", jwksMaxAdvance, v["activeKid"])
+	}
+}
+
+func TestJwksScheduleRejectsNegativeMaxAge(t *testing.T) {
+	w := httptest.NewRecorder()
+	newServer().jwksschedule(w, httptest.NewRequest("GET", "/jwksschedule?maxage=-5", nil))
+	if w.Code != 400 {
+		t.Errorf("response status code want 400, got %v", w.Code)
+	}
+	if cc := w.Header().Get("Cache-Control"); strings.Contains(cc, "max-age") {
+		t.Errorf("want no max-age for negative maxage, got %v", cc)
+	}
+}
+
+func TestJwksRotateAlternates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().jwksrotate))
+	defer srv.Close()
+
+	for i, want := range []string{"k1", "k2", "k1", "k1"} {
+		q := ""
+		if i == 3 {
+			q = "?rc=0"
+		}
+		res, err := http.Get(srv.URL + q)
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if !strings.Contains(string(b), `"kid": "`+want+`"`) {
+			t.Errorf("request %d want kid %s, got %s", i, want, b)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+type acceptItem struct {
+	value string
+	q     float64
+}
+
+// parseAccept parses a RFC 7231 Accept style header into its values and q-values. Media type parameters
+// other than q are dropped.
+func parseAccept(h string) []acceptItem {
+	items := make([]acceptItem, 0)
+	for _, part := range strings.Split(h, ",") {
+		params := strings.Split(part, ";")
+		v := strings.ToLower(strings.TrimSpace(params[0]))
+		if len(v) == 0 {
+			continue
+		}
+		q := 1.0
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if f, err := strconv.ParseFloat(kv[1], 64); err == nil && f >= 0 && f <= 1 {
+					q = f
+				} else {
+					q = 0
+				}
+			}
+		}
+		items = append(items, acceptItem{value: v, q: q})
+	}
+	return items
+}
+
+// acceptMatch returns the specificity with which an accept value matches an offer, or -1.
+type acceptMatch func(accepted string, offer string) int
+
+func matchMediaType(accepted string, offer string) int {
+	switch {
+	case accepted == offer:
+		return 2
+	case accepted == "*/*":
+		return 0
+	case strings.HasSuffix(accepted, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(accepted, "*")):
+		return 1
+	}
+	return -1
+}
+
+// matchLanguage matches language ranges by prefix, falling back to the RFC 4647 section 3.4 lookup that truncates
+// the range from the end, so en-US accepts en. Single character subtags left over by truncation are dropped too.
+func matchLanguage(accepted string, offer string) int {
+	switch {
+	case accepted == offer:
+		return 2
+	case accepted == "*":
+		return 0
+	case strings.HasPrefix(offer, accepted+"-"):
+		return 1
+	}
+	for i := strings.LastIndex(accepted, "-"); i > 0; i = strings.LastIndex(accepted, "-") {
+		accepted = accepted[:i]
+		if j := strings.LastIndex(accepted, "-"); j >= 0 && j == len(accepted)-2 {
+			accepted = accepted[:j]
+		}
+		if accepted == offer {
+			return 1
+		}
+	}
+	return -1
+}
+
+func matchToken(accepted string, offer string) int {
+	switch {
+	case accepted == offer:
+		return 1
+	case accepted == "*":
+		return 0
+	}
+	return -1
+}
+
+// acceptQuality returns the q-value of the most specific accept value matching offer, or -1 if none match.
+func acceptQuality(items []acceptItem, offer string, match acceptMatch) float64 {
+	q, best := -1.0, -1
+	for _, i := range items {
+		if s := match(i.value, offer); s > best {
+			q, best = i.q, s
+		}
+	}
+	return q
+}
+
+// negotiate picks the offer with the highest q-value, ties are broken by the order of offers. An absent header
+// accepts the first offer. It returns false if no offer is acceptable.
+func negotiate(header []string, offers []string, match acceptMatch) (string, bool) {
+	if len(header) == 0 {
+		return offers[0], true
+	}
+	return negotiateItems(parseAccept(strings.Join(header, ",")), offers, match)
+}
+
+func negotiateItems(items []acceptItem, offers []string, match acceptMatch) (string, bool) {
+	chosen, chosenQ := "", 0.0
+	for _, o := range offers {
+		if q := acceptQuality(items, o, match); q > chosenQ {
+			chosen, chosenQ = o, q
+		}
+	}
+	return chosen, chosenQ > 0
+}
+
+// negotiateEncoding follows RFC 7231 section 5.3.4. identity is acceptable unless excluded by identity;q=0
+// or *;q=0, so it is offered last with an implicit low preference.
+func negotiateEncoding(header []string, offers []string) (string, bool) {
+	if len(header) == 0 {
+		return "identity", true
+	}
+	items := parseAccept(strings.Join(header, ","))
+	if acceptQuality(items, "identity", matchToken) < 0 {
+		items = append(items, acceptItem{value: "identity", q: 0.001})
+	}
+	return negotiateItems(items, append(append([]string{}, offers...), "identity"), matchToken)
+}
+
+var negotiateTypes = []string{"application/json", "text/plain", "text/html", "application/xml"}
+var negotiateLanguages = []string{"en", "de", "fr", "ja"}
+var negotiateCharsets = []string{"utf-8", "iso-8859-1", "utf-16"}
+var negotiateEncodings = []string{"br", "zstd", "gzip", "deflate"}
+
+var negotiateGreetings = map[string]string{
+	"en": "Hello from the negotiate endpoint",
+	"de": "Grüße vom negotiate Endpunkt",
+	"fr": "Bonjour de la part du négociateur",
+	"ja": "negotiate エンドポイントからこんにちは",
+}
+
+func negotiateBody(contentType string, lang string) string {
+	g := negotiateGreetings[lang]
+	switch contentType {
+	case "text/plain":
+		return g
+	case "text/html":
+		return fmt.Sprintf(`<html lang="%s"><body><p>%s</p></body></html>`, lang, g)
+	case "application/xml":
+		return fmt.Sprintf(`<mse6 lang="%s">%s</mse6>`, lang, g)
+	}
+	return fmt.Sprintf(`{"mse6":"%s", "lang":"%s"}`, g, lang)
+}
+
+// encodeCharset encodes s in the charset. Runes outside of ISO-8859-1 are replaced with ?.
+func encodeCharset(s string, charset string) []byte {
+	switch charset {
+	case "iso-8859-1":
+		b := make([]byte, 0, len(s))
+		for _, r := range s {
+			if r > 255 {
+				r = '?'
+			}
+			b = append(b, byte(r))
+		}
+		return b
+	case "utf-16":
+		u := utf16.Encode([]rune(s))
+		b := []byte{0xfe, 0xff}
+		for _, c := range u {
+			b = append(b, byte(c>>8), byte(c))
+		}
+		return b
+	}
+	return []byte(s)
+}
+
+// contentEncode encodes b with a registered codec. identity and unknown encodings leave b unchanged.
+func contentEncode(b []byte, encoding string) ([]byte, error) {
+	if _, ok := codecs.get(encoding); !ok {
+		return b, nil
+	}
+	return encodeBytes(encoding, b)
+}
+
+func send406(w http.ResponseWriter, r *http.Request, header string) {
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.WriteHeader(406)
+	w.Write([]byte(fmt.Sprintf(`{"mse6":"406", "unacceptable":"%s"}`, header)))
+	log.Info().Msgf("served %v request with X-Request-Id %s code 406 nothing acceptable for %s %v", r.URL.Path, getXRequestId(r), header, r.Header[header])
+}
+
+// negotiatef serves a greeting negotiated by Accept, Accept-Language, Accept-Charset and Accept-Encoding,
+// or 406 if nothing is acceptable. ignore=true skips negotiation and always sends the first offer of each.
+func negotiatef(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Vary", "Accept, Accept-Language, Accept-Charset, Accept-Encoding")
+	ct, lang, cs, enc := negotiateTypes[0], negotiateLanguages[0], negotiateCharsets[0], negotiateEncodings[0]
+
+	if r.URL.Query().Get("ignore") != "true" {
+		var ok bool
+		if ct, ok = negotiate(r.Header["Accept"], negotiateTypes, matchMediaType); !ok {
+			send406(w, r, "Accept")
+			return
+		}
+		if lang, ok = negotiate(r.Header["Accept-Language"], negotiateLanguages, matchLanguage); !ok {
+			send406(w, r, "Accept-Language")
+			return
+		}
+		if cs, ok = negotiate(r.Header["Accept-Charset"], negotiateCharsets, matchToken); !ok {
+			send406(w, r, "Accept-Charset")
+			return
+		}
+		if enc, ok = negotiateEncoding(r.Header["Accept-Encoding"], negotiateEncodings); !ok {
+			send406(w, r, "Accept-Encoding")
+			return
+		}
+	}
+
+	b, err := contentEncode(encodeCharset(negotiateBody(ct, lang), cs), enc)
+	if err != nil {
+		sendEncodingError(w, r, enc, err)
+		return
+	}
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Type", ct+"; charset="+cs)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Content-Encoding", enc)
+	w.WriteHeader(200)
+	w.Write(b)
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 negotiated %s %s %s %s", r.URL.Path, getXRequestId(r), ct, lang, cs, enc)
+}
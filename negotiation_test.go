@@ -0,0 +1,113 @@
+package mse6
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   string
+		ok     bool
+	}{
+		{nil, "identity", true},
+		{[]string{""}, "identity", true},
+		{[]string{"gzip, deflate, br"}, "br", true},
+		{[]string{"br;q=0, gzip"}, "gzip", true},
+		{[]string{"gzip;q=0.5, deflate;q=0.8"}, "deflate", true},
+		{[]string{"identity;q=0"}, "", false},
+		{[]string{"*;q=0"}, "", false},
+		{[]string{"*;q=0, identity"}, "identity", true},
+		{[]string{"zstd"}, "zstd", true},
+		{[]string{"compress"}, "identity", true},
+		{[]string{"compress, identity;q=0"}, "", false},
+		{[]string{"*"}, "br", true},
+		{[]string{"GZIP"}, "gzip", true},
+	}
+	for _, tt := range tests {
+		got, ok := negotiateEncoding(tt.header, negotiateEncodings)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Accept-Encoding %v want %v %v, got %v %v", tt.header, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestChooseRejectsUnacceptable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(chooseaef))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "identity;q=0")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != 406 {
+		t.Errorf("response status code want 406, got %v", res.StatusCode)
+	}
+	if res.Header.Get("Vary") != "Accept-Encoding" {
+		t.Errorf("want Vary Accept-Encoding, got %v", res.Header.Get("Vary"))
+	}
+}
+
+func TestNegotiateResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(negotiatef))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		query    string
+		accept   string
+		language string
+		charset  string
+		encoding string
+		code     int
+		ct       string
+		lang     string
+		ce       string
+	}{
+		{"defaults", "", "", "", "", "identity", 200, "application/json; charset=utf-8", "en", "identity"},
+		{"type range", "", "text/*;q=0.9, application/json;q=0.1", "", "", "identity", 200, "text/plain; charset=utf-8", "en", "identity"},
+		{"language lookup", "", "", "fr-CH, de;q=0.9", "", "identity", 200, "application/json; charset=utf-8", "fr", "identity"},
+		{"language lookup region", "", "", "en-US", "", "identity", 200, "application/json; charset=utf-8", "en", "identity"},
+		{"language lookup prefers exact", "", "", "de-AT, en;q=0.5, de;q=0.1", "", "identity", 200, "application/json; charset=utf-8", "en", "identity"},
+		{"charset", "", "text/html", "", "iso-8859-1", "gzip", 200, "text/html; charset=iso-8859-1", "en", "gzip"},
+		{"type not acceptable", "", "image/png", "", "", "", 406, "", "", "identity"},
+		{"language not acceptable", "", "", "es", "", "", 406, "", "", "identity"},
+		{"charset not acceptable", "", "", "", "utf-32", "", 406, "", "", "identity"},
+		{"ignore", "?ignore=true", "image/png", "es", "utf-32", "identity", 200, "application/json; charset=utf-8", "en", "br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+tt.query, nil)
+			for k, v := range map[string]string{"Accept": tt.accept, "Accept-Language": tt.language, "Accept-Charset": tt.charset, "Accept-Encoding": tt.encoding} {
+				if len(v) > 0 {
+					req.Header.Set(k, v)
+				}
+			}
+			res, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if tt.code == 200 && (res.Header.Get("Content-Type") != tt.ct || res.Header.Get("Content-Language") != tt.lang) {
+				t.Errorf("want %v %v, got %v %v", tt.ct, tt.lang, res.Header.Get("Content-Type"), res.Header.Get("Content-Language"))
+			}
+			if res.Header.Get("Content-Encoding") != tt.ce {
+				t.Errorf("Content-Encoding want %v, got %v", tt.ce, res.Header.Get("Content-Encoding"))
+			}
+			if len(res.Header.Get("Vary")) == 0 {
+				t.Errorf("want Vary header")
+			}
+		})
+	}
+}
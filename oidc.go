@@ -0,0 +1,275 @@
+package mse6
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const oidcClientId = "mse6"
+const oidcClientSecret = "mse6"
+const oidcUsername = "mse6"
+const oidcPassword = "mse6"
+const oidcAudience = "mse6"
+const oidcTokenSeconds = 3600
+const oidcRSAKid = "mse6-rs256"
+const oidcECKid = "mse6-es256"
+
+// oidcProvider holds the signing keys of the local OIDC provider. Keys are generated on first use.
+type oidcProvider struct {
+	once   sync.Once
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	//rogue signs tokens that claim the kid of rsaKey but don't verify against it.
+	rogue *rsa.PrivateKey
+}
+
+func (p *oidcProvider) keys() *oidcProvider {
+	p.once.Do(func() {
+		p.rsaKey = mustGenerateRSAKey()
+		p.rogue = mustGenerateRSAKey()
+		p.ecKey = mustGenerateECKey()
+		log.Info().Msgf("generated oidc signing keys %s and %s", oidcRSAKid, oidcECKid)
+	})
+	return p
+}
+
+func mustGenerateRSAKey() *rsa.PrivateKey {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err.Error())
+	}
+	return k
+}
+
+func mustGenerateECKey() *ecdsa.PrivateKey {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err.Error())
+	}
+	return k
+}
+
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signJWT serialises a compact JWS. alg is one of RS256, ES256 or none, key must match alg.
+func signJWT(alg string, kid string, key crypto.Signer, claims map[string]interface{}) (string, error) {
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if len(kid) > 0 {
+		header["kid"] = kid
+	}
+	hb, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := b64url(hb) + "." + b64url(cb)
+	if alg == "none" {
+		return input + ".", nil
+	}
+
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			sig = make([]byte, 64)
+			rb, sb := r.Bytes(), s.Bytes()
+			copy(sig[32-len(rb):32], rb)
+			copy(sig[64-len(sb):], sb)
+		}
+	default:
+		err = fmt.Errorf("unsupported signing key type %T", key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return input + "." + b64url(sig), nil
+}
+
+func rsaJWK(kid string, k *rsa.PublicKey) map[string]interface{} {
+	return map[string]interface{}{
+		"kty": "RSA",
+		"alg": "RS256",
+		"use": "sig",
+		"kid": kid,
+		"n":   b64url(k.N.Bytes()),
+		"e":   b64url(big.NewInt(int64(k.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, k *ecdsa.PublicKey) map[string]interface{} {
+	x, y := make([]byte, 32), make([]byte, 32)
+	xb, yb := k.X.Bytes(), k.Y.Bytes()
+	copy(x[32-len(xb):], xb)
+	copy(y[32-len(yb):], yb)
+	return map[string]interface{}{
+		"kty": "EC",
+		"alg": "ES256",
+		"use": "sig",
+		"crv": "P-256",
+		"kid": kid,
+		"x":   b64url(x),
+		"y":   b64url(y),
+	}
+}
+
+func oidcIssuer(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%soidc", scheme, r.Host, Prefix)
+}
+
+func sendJSON(w http.ResponseWriter, code int, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Error().Msgf("unable to marshal json response with code %d, sending 500 instead, cause: %v", code, err)
+		code = 500
+		b = []byte(`{"mse6":"500"}`)
+	}
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("Content-Type", "application/json")
+	if len(w.Header().Get("Cache-Control")) == 0 {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+func oidcdiscovery(w http.ResponseWriter, r *http.Request) {
+	iss := oidcIssuer(r)
+	sendJSON(w, 200, map[string]interface{}{
+		"issuer":                                iss,
+		"token_endpoint":                        iss + "/token",
+		"jwks_uri":                              iss + "/jwks",
+		"grant_types_supported":                 []string{"client_credentials", "password"},
+		"response_types_supported":              []string{"token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256", "ES256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+	})
+	log.Info().Msgf("served %v oidc discovery request with X-Request-Id %s code %d", r.URL.Path, getXRequestId(r), 200)
+}
+
+func (s *server) oidcjwks(w http.ResponseWriter, r *http.Request) {
+	p := s.state.oidc.keys()
+	sendJSON(w, 200, map[string]interface{}{
+		"keys": []interface{}{
+			rsaJWK(oidcRSAKid, &p.rsaKey.PublicKey),
+			ecJWK(oidcECKid, &p.ecKey.PublicKey),
+		},
+	})
+	log.Info().Msgf("served %v oidc jwks request with X-Request-Id %s code %d", r.URL.Path, getXRequestId(r), 200)
+}
+
+// oidctoken issues access tokens for the client_credentials and password grants. The fault parameter
+// issues expired, badsig (wrongly signed), wrongaud or none (alg: none) tokens.
+func (s *server) oidctoken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		send405(w, r)
+		return
+	}
+	r.ParseForm()
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != oidcClientId || secret != oidcClientSecret {
+		w.Header().Set("WWW-Authenticate", `Basic realm="mse6"`)
+		sendJSON(w, 401, map[string]string{"error": "invalid_client"})
+		log.Info().Msgf("served %v oidc token request with X-Request-Id %s code %d invalid client %s", r.URL.Path, getXRequestId(r), 401, id)
+		return
+	}
+
+	sub := id
+	switch gt := r.PostForm.Get("grant_type"); gt {
+	case "client_credentials":
+	case "password":
+		if r.PostForm.Get("username") != oidcUsername || r.PostForm.Get("password") != oidcPassword {
+			sendJSON(w, 400, map[string]string{"error": "invalid_grant"})
+			log.Info().Msgf("served %v oidc token request with X-Request-Id %s code %d invalid grant", r.URL.Path, getXRequestId(r), 400)
+			return
+		}
+		sub = r.PostForm.Get("username")
+	default:
+		sendJSON(w, 400, map[string]string{"error": "unsupported_grant_type"})
+		log.Info().Msgf("served %v oidc token request with X-Request-Id %s code %d unsupported grant type %s", r.URL.Path, getXRequestId(r), 400, gt)
+		return
+	}
+
+	fault := r.FormValue("fault")
+	now := time.Now()
+	exp := now.Add(oidcTokenSeconds * time.Second)
+	if fault == "expired" {
+		now = now.Add(-2 * oidcTokenSeconds * time.Second)
+		exp = now.Add(oidcTokenSeconds * time.Second)
+	}
+	aud := oidcAudience
+	if fault == "wrongaud" {
+		aud = "not-" + oidcAudience
+	}
+	claims := map[string]interface{}{
+		"iss":       oidcIssuer(r),
+		"sub":       sub,
+		"aud":       aud,
+		"azp":       id,
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       exp.Unix(),
+		"jti":       fmt.Sprintf("%d", now.UnixNano()),
+		"client_id": id,
+		"scope":     r.PostForm.Get("scope"),
+	}
+
+	p := s.state.oidc.keys()
+	alg, kid, key := "RS256", oidcRSAKid, crypto.Signer(p.rsaKey)
+	if strings.ToUpper(r.FormValue("alg")) == "ES256" {
+		alg, kid, key = "ES256", oidcECKid, crypto.Signer(p.ecKey)
+	}
+	switch fault {
+	case "badsig":
+		alg, kid, key = "RS256", oidcRSAKid, crypto.Signer(p.rogue)
+	case "none":
+		alg, kid = "none", ""
+	}
+
+	tok, err := signJWT(alg, kid, key, claims)
+	if err != nil {
+		sendJSON(w, 500, map[string]string{"error": "server_error", "error_description": err.Error()})
+		log.Warn().Msgf("unable to sign oidc token for X-Request-Id %s, cause: %s", getXRequestId(r), err)
+		return
+	}
+
+	sendJSON(w, 200, map[string]interface{}{
+		"access_token": tok,
+		"token_type":   "Bearer",
+		"expires_in":   int(exp.Sub(time.Now()).Seconds()),
+		"scope":        r.PostForm.Get("scope"),
+	})
+	log.Info().Msgf("served %v oidc token request with X-Request-Id %s code %d alg %s fault '%s'", r.URL.Path, getXRequestId(r), 200, alg, fault)
+}
@@ -0,0 +1,149 @@
+package mse6
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// verifyJWT checks the signature of a compact JWS against a JWKS and returns its claims.
+func verifyJWT(t *testing.T, tok string, jwks map[string]interface{}) (map[string]interface{}, bool) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		t.Errorf("token not in compact serialisation: %v", tok)
+		return nil, false
+	}
+	hb, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	cb, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	var header, claims map[string]interface{}
+	json.Unmarshal(hb, &header)
+	json.Unmarshal(cb, &claims)
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	for _, k := range jwks["keys"].([]interface{}) {
+		jwk := k.(map[string]interface{})
+		if jwk["kid"] != header["kid"] {
+			continue
+		}
+		dec := func(s interface{}) *big.Int {
+			b, _ := base64.RawURLEncoding.DecodeString(s.(string))
+			return new(big.Int).SetBytes(b)
+		}
+		switch header["alg"] {
+		case "RS256":
+			pub := &rsa.PublicKey{N: dec(jwk["n"]), E: int(dec(jwk["e"]).Int64())}
+			return claims, rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+		case "ES256":
+			pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: dec(jwk["x"]), Y: dec(jwk["y"])}
+			if len(sig) != 64 {
+				return claims, false
+			}
+			return claims, ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+		}
+	}
+	return claims, false
+}
+
+func getJSON(t *testing.T, u string) map[string]interface{} {
+	res, err := http.Get(u)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return nil
+	}
+	defer res.Body.Close()
+	var v map[string]interface{}
+	b, _ := ioutil.ReadAll(res.Body)
+	json.Unmarshal(b, &v)
+	return v
+}
+
+func TestOidcTokenValidatesAgainstJwks(t *testing.T) {
+	s := newServer()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oidc/jwks", s.oidcjwks)
+	mux.HandleFunc("/oidc/token", s.oidctoken)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	jwks := getJSON(t, srv.URL+"/oidc/jwks")
+
+	tests := []struct {
+		name     string
+		query    string
+		form     url.Values
+		code     int
+		valid    bool
+		audience string
+	}{
+		{"client credentials", "", url.Values{"grant_type": {"client_credentials"}, "client_id": {"mse6"}, "client_secret": {"mse6"}}, 200, true, "mse6"},
+		{"password es256", "?alg=ES256", url.Values{"grant_type": {"password"}, "client_id": {"mse6"}, "client_secret": {"mse6"}, "username": {"mse6"}, "password": {"mse6"}}, 200, true, "mse6"},
+		{"badsig", "?fault=badsig", url.Values{"grant_type": {"client_credentials"}, "client_id": {"mse6"}, "client_secret": {"mse6"}}, 200, false, "mse6"},
+		{"wrongaud", "?fault=wrongaud", url.Values{"grant_type": {"client_credentials"}, "client_id": {"mse6"}, "client_secret": {"mse6"}}, 200, true, "not-mse6"},
+		{"bad client", "", url.Values{"grant_type": {"client_credentials"}, "client_id": {"mse6"}, "client_secret": {"wrong"}}, 401, false, ""},
+		{"bad password", "", url.Values{"grant_type": {"password"}, "client_id": {"mse6"}, "client_secret": {"mse6"}, "username": {"mse6"}, "password": {"wrong"}}, 400, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.PostForm(srv.URL+"/oidc/token"+tt.query, tt.form)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if tt.code != 200 {
+				return
+			}
+
+			var tr map[string]interface{}
+			json.Unmarshal(b, &tr)
+			claims, valid := verifyJWT(t, tr["access_token"].(string), jwks)
+			if valid != tt.valid {
+				t.Errorf("token signature valid want %v, got %v", tt.valid, valid)
+			}
+			if claims["aud"] != tt.audience {
+				t.Errorf("token audience want %v, got %v", tt.audience, claims["aud"])
+			}
+		})
+	}
+}
+
+func TestOidcDiscoveryResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(oidcdiscovery))
+	defer srv.Close()
+
+	d := getJSON(t, srv.URL)
+	if !strings.HasSuffix(d["jwks_uri"].(string), "oidc/jwks") {
+		t.Errorf("discovery jwks_uri want suffix oidc/jwks, got %v", d["jwks_uri"])
+	}
+	if !strings.HasSuffix(d["token_endpoint"].(string), "oidc/token") {
+		t.Errorf("discovery token_endpoint want suffix oidc/token, got %v", d["token_endpoint"])
+	}
+}
+
+func TestSendJSONMarshalError(t *testing.T) {
+	w := httptest.NewRecorder()
+	sendJSON(w, 200, map[string]interface{}{"n": json.Number("+1")})
+	if w.Code != 500 {
+		t.Errorf("response status code want 500, got %v", w.Code)
+	}
+	if w.Body.String() != `{"mse6":"500"}` {
+		t.Errorf("want 500 body, got %v", w.Body.String())
+	}
+}
@@ -0,0 +1,144 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const rateLimitDefault = 5
+const rateLimitWindowSeconds = 60
+const rateLimitSweepInterval = time.Minute
+
+type rateLimitBucket struct {
+	tokens      float64
+	last        time.Time
+	windowStart time.Time
+	count       int
+	window      time.Duration
+	seen        time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateLimitBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*rateLimitBucket)}
+}
+
+// sweep evicts buckets that were idle for a full window. They would allow the full limit again, same as a new one.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.seen) >= b.window {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// allow consumes one request for key and returns whether it was allowed, the remaining quota
+// and the number of seconds until the quota resets.
+func (l *rateLimiter) allow(key string, algo string, limit int, window time.Duration, now time.Time) (bool, int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateLimitBucket{tokens: float64(limit), last: now, windowStart: now, window: window}
+		l.buckets[key] = b
+	}
+	b.seen = now
+
+	if algo == "bucket" {
+		rate := float64(limit) / window.Seconds()
+		b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			return true, int(b.tokens), int(math.Ceil((float64(limit) - b.tokens) / rate))
+		}
+		return false, 0, int(math.Ceil((1 - b.tokens) / rate))
+	}
+
+	if now.Sub(b.windowStart) >= window {
+		b.windowStart = now
+		b.count = 0
+	}
+	reset := int(math.Ceil(b.windowStart.Add(window).Sub(now).Seconds()))
+	if b.count < limit {
+		b.count++
+		return true, limit - b.count, reset
+	}
+	return false, 0, reset
+}
+
+// ratelimit wraps a handler with a fixed window or token bucket rate limit. Counters are scoped
+// per route, per limiter configuration and per client key, i.e. remote address or an api key header.
+func (s *server) ratelimit(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parseQueryInt(r, "limit", rateLimitDefault)
+		if limit < 1 {
+			limit = rateLimitDefault
+		}
+		window := time.Duration(parseQueryInt(r, "window", rateLimitWindowSeconds)) * time.Second
+		if window < time.Second {
+			window = rateLimitWindowSeconds * time.Second
+		}
+		algo := "window"
+		if r.URL.Query().Get("algo") == "bucket" {
+			algo = "bucket"
+		}
+
+		key := rateLimitClientKey(r)
+		bucketKey := fmt.Sprintf("%s|%s|%d|%d|%s", r.URL.Path, algo, limit, int(window.Seconds()), key)
+		ok, remaining, reset := s.state.limiter.allow(bucketKey, algo, limit, window, time.Now())
+
+		w.Header().Set("RateLimit-Limit", fmt.Sprintf("%d", limit))
+		w.Header().Set("RateLimit-Remaining", fmt.Sprintf("%d", remaining))
+		w.Header().Set("RateLimit-Reset", fmt.Sprintf("%d", reset))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit, int(window.Seconds())))
+
+		if ok {
+			f(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("retry") == "date" {
+			w.Header().Set("Retry-After", time.Now().Add(time.Duration(reset)*time.Second).UTC().Format(http.TimeFormat))
+		} else {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", reset))
+		}
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(429)
+		w.Write([]byte(`{"mse6":"429"}`))
+
+		log.Info().Msgf("served %v request with X-Request-Id %s response code 429 for client key %s, retry after %d seconds", r.URL.Path, getXRequestId(r), key, reset)
+	}
+}
+
+func rateLimitClientKey(r *http.Request) string {
+	if r.URL.Query().Get("key") == "header" {
+		h := "X-Api-Key"
+		if len(r.URL.Query().Get("header")) > 0 {
+			h = r.URL.Query().Get("header")
+		}
+		return h + ":" + r.Header.Get(h)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
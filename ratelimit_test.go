@@ -0,0 +1,85 @@
+package mse6
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitResponds(t *testing.T) {
+	srv := httptest.NewServer(newServer().ratelimit(get))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		apiKey string
+		code   int
+	}{
+		{"first", "k1", 200},
+		{"second", "k1", 200},
+		{"limited", "k1", 429},
+		{"otherkey", "k2", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+"?limit=2&window=30&key=header", nil)
+			req.Header.Set("X-Api-Key", tt.apiKey)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v", tt.code, res.StatusCode)
+			}
+			if tt.code == 429 && res.Header.Get("Retry-After") == "" {
+				t.Errorf("want Retry-After header on 429, got none")
+			}
+			if res.Header.Get("RateLimit-Limit") != "2" {
+				t.Errorf("want RateLimit-Limit 2, got %v", res.Header.Get("RateLimit-Limit"))
+			}
+		})
+	}
+}
+
+func TestRateLimiterTokenBucketRefills(t *testing.T) {
+	l := newRateLimiter()
+	now := time.Now()
+
+	if ok, _, _ := l.allow("k", "bucket", 1, time.Second*10, now); !ok {
+		t.Errorf("first request should be allowed")
+	}
+	ok, _, retry := l.allow("k", "bucket", 1, time.Second*10, now)
+	if ok {
+		t.Errorf("second request should be limited")
+	}
+	if retry != 10 {
+		t.Errorf("want retry after 10 seconds, got %v", retry)
+	}
+	if ok, _, _ := l.allow("k", "bucket", 1, time.Second*10, now.Add(time.Second*10)); !ok {
+		t.Errorf("request should be allowed after bucket refilled")
+	}
+}
+
+func TestRateLimiterEvictsIdleBuckets(t *testing.T) {
+	l := newRateLimiter()
+	now := time.Now()
+
+	l.allow("idle", "window", 1, time.Second*10, now)
+	l.allow("busy", "window", 1, time.Minute*10, now)
+	l.allow("new", "window", 1, time.Second*10, now.Add(rateLimitSweepInterval))
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Errorf("want idle bucket evicted")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Errorf("want bucket inside its window kept")
+	}
+	if len(l.buckets) != 2 {
+		t.Errorf("want 2 buckets, got %d", len(l.buckets))
+	}
+}
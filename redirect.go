@@ -0,0 +1,131 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var malformedLocations = []string{
+	"http://[::1",
+	"ht tp://mse6/redirect",
+	"http://mse6:99999/redirect",
+	"%zz",
+	"http://mse6/red\x7firect",
+	"",
+}
+
+type redirectEcho struct {
+	Method        string `json:"method"`
+	Hops          int    `json:"hops"`
+	Scheme        string `json:"scheme"`
+	Host          string `json:"host"`
+	Authorization bool   `json:"authorization"`
+	Cookie        bool   `json:"cookie"`
+	ContentType   string `json:"contentType"`
+	BodySize      int    `json:"bodySize"`
+	BodySHA256    string `json:"bodySha256"`
+}
+
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// redirectLocation builds the Location of the next hop. Relative locations are resolved by the client,
+// absolute locations switch scheme, host and port if requested.
+func redirectLocation(r *http.Request, hop int) string {
+	q := r.URL.Query()
+	q.Set("hop", strconv.Itoa(hop))
+	path := r.URL.Path + "?" + q.Encode()
+
+	switch q.Get("location") {
+	case "relative":
+		return path[strings.LastIndex(r.URL.Path, "/")+1:]
+	case "path":
+		return path
+	}
+
+	scheme := requestScheme(r)
+	if len(q.Get("scheme")) > 0 {
+		scheme = q.Get("scheme")
+	}
+	host := r.Host
+	if len(q.Get("host")) > 0 {
+		host = q.Get("host")
+	}
+	if len(q.Get("port")) > 0 {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = net.JoinHostPort(host, q.Get("port"))
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, host, path)
+}
+
+// redirect follows a chain of n redirect hops, then echoes the final request as JSON. codes sets the status
+// code per hop and repeats, loop=true redirects to itself forever. scheme, host and port switch the target of
+// each hop, location=relative|path changes the form of the Location header and location=malformed sends
+// the malformed Location selected by malformed.
+func redirect(w http.ResponseWriter, r *http.Request) {
+	n := parseQueryInt(r, "n", 3)
+	hop := parseQueryInt(r, "hop", 0)
+	loop := r.URL.Query().Get("loop") == "true"
+	if hop < 0 {
+		sendJSON(w, 400, map[string]string{"mse6": "400", "error": "hop must not be negative"})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 negative hop %d", r.URL.Path, getXRequestId(r), hop)
+		return
+	}
+
+	if hop < n || loop {
+		codes := strings.Split(r.URL.Query().Get("codes"), ",")
+		code, err := strconv.Atoi(codes[hop%len(codes)])
+		if err != nil || code < 300 || code > 399 {
+			code = 302
+		}
+		next := hop + 1
+		if loop {
+			next = hop
+		}
+		location := redirectLocation(r, next)
+		if r.URL.Query().Get("location") == "malformed" {
+			m := parseQueryInt(r, "malformed", 0)
+			if m < 0 || m >= len(malformedLocations) {
+				m = 0
+			}
+			location = malformedLocations[m]
+		}
+
+		//drain the body so the connection can be reused, 307 and 308 require the client to send it again.
+		ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		w.Header()["Location"] = []string{location}
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(code)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"%d", "hop":%d}`, code, hop)))
+		log.Info().Msgf("served %v %s request with X-Request-Id %s code %d hop %d redirect to %s", r.URL.Path, r.Method, getXRequestId(r), code, hop, location)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	sendJSON(w, 200, redirectEcho{
+		Method:        r.Method,
+		Hops:          hop,
+		Scheme:        requestScheme(r),
+		Host:          r.Host,
+		Authorization: len(r.Header.Get("Authorization")) > 0,
+		Cookie:        len(r.Header.Get("Cookie")) > 0,
+		ContentType:   r.Header.Get("Content-Type"),
+		BodySize:      len(body),
+		BodySHA256:    sha256Hex(body),
+	})
+	log.Info().Msgf("served %v %s request with X-Request-Id %s code 200 after %d redirect hops", r.URL.Path, r.Method, getXRequestId(r), hop)
+}
@@ -0,0 +1,88 @@
+package mse6
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(redirect))
+	defer srv.Close()
+	otherHost := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	otherHost = strings.TrimPrefix(otherHost, "http://")
+
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		want   redirectEcho
+	}{
+		{"chain", "GET", "?n=3", redirectEcho{Method: "GET", Hops: 3, Authorization: true}},
+		{"303 to GET", "POST", "?n=1&codes=303", redirectEcho{Method: "GET", Hops: 1, Authorization: true}},
+		{"307 and 308 keep method and body", "POST", "?n=2&codes=307,308", redirectEcho{Method: "POST", Hops: 2, Authorization: true, BodySize: 4}},
+		{"relative", "GET", "?n=2&location=relative", redirectEcho{Method: "GET", Hops: 2, Authorization: true}},
+		{"path", "GET", "?n=2&location=path", redirectEcho{Method: "GET", Hops: 2, Authorization: true}},
+		{"cross host strips authorization", "GET", "?n=1&host=" + otherHost, redirectEcho{Method: "GET", Hops: 1, Authorization: false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+"/mse6/redirect"+tt.query, bytes.NewBufferString("mse6"))
+			req.Header.Set("Authorization", "Bearer mse6")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			var e redirectEcho
+			json.NewDecoder(res.Body).Decode(&e)
+			res.Body.Close()
+			if e.Method != tt.want.Method || e.Hops != tt.want.Hops || e.Authorization != tt.want.Authorization || e.BodySize != tt.want.BodySize {
+				t.Errorf("redirect echo want %+v, got %+v", tt.want, e)
+			}
+		})
+	}
+}
+
+func TestRedirectFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(redirect))
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"loop", "?loop=true", "stopped after 10 redirects"},
+		{"too many hops", "?n=11", "stopped after 10 redirects"},
+		{"malformed", "?location=malformed&malformed=0", "failed to parse Location header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := http.Get(srv.URL + "/mse6/redirect" + tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("want client error %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRedirectNegativeHop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(redirect))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/mse6/redirect?hop=-1&codes=301,302")
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != 400 {
+		t.Errorf("response status code want 400, got %v", res.StatusCode)
+	}
+}
@@ -0,0 +1,132 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sequenceDefaultSteps = "503,503,hangup,200"
+
+type sequenceStore struct {
+	mu       sync.Mutex
+	counters map[string]int
+	max      int
+}
+
+// next returns the zero based request count for key and increments it. If max is set, a new key beyond max
+// counters drops an arbitrary other counter.
+func (s *sequenceStore) next(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[key]
+	if !ok && s.max > 0 && len(s.counters) >= s.max {
+		for k := range s.counters {
+			delete(s.counters, k)
+			break
+		}
+	}
+	s.counters[key] = c + 1
+	return c
+}
+
+// reset clears all counters for the sequence id, or every counter if id is empty.
+func (s *sequenceStore) reset(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k := range s.counters {
+		if len(id) == 0 || strings.HasPrefix(k, id+"|") {
+			delete(s.counters, k)
+			n++
+		}
+	}
+	return n
+}
+
+// sequence responds with a scripted list of behaviours, one per request, in order. Each step is either
+// a status code, "hangup" to close the TCP connection, or "slow" to wait before sending 200.
+func (s *server) sequence(w http.ResponseWriter, r *http.Request) {
+	stepsParam := sequenceDefaultSteps
+	if len(r.URL.Query().Get("steps")) > 0 {
+		stepsParam = r.URL.Query().Get("steps")
+	}
+	steps := strings.Split(stepsParam, ",")
+
+	id := "sequence"
+	if len(r.URL.Query().Get("id")) > 0 {
+		id = r.URL.Query().Get("id")
+	}
+	key := id + "|" + sequenceScopeKey(r)
+	c := s.state.sequences.next(key)
+
+	i := c % len(steps)
+	if r.URL.Query().Get("mode") == "stick" && c >= len(steps) {
+		i = len(steps) - 1
+	}
+	step := strings.TrimSpace(steps[i])
+
+	switch step {
+	case "hangup":
+		hj, _ := w.(http.Hijacker)
+		conn, _, _ := hj.Hijack()
+		conn.Close()
+		log.Info().Msgf("served %v sequence %s step %d with hard conn close, X-Request-Id %s", r.URL.Path, key, i, getXRequestId(r))
+		return
+	case "slow":
+		wd := parseWaitDuration(r)
+		time.Sleep(wd)
+		step = "200"
+	}
+
+	code, err := strconv.Atoi(step)
+	if err != nil || !(code > 199 && code < 1000) {
+		log.Warn().Msgf("unable to parse sequence step %s, using 200", step)
+		code = 200
+	}
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("X-Mse6-Sequence-Step", fmt.Sprintf("%d", i))
+	w.WriteHeader(code)
+	w.Write([]byte(fmt.Sprintf(`{"mse6":"%d", "sequence":"%s", "step":"%d", "count":"%d"}`, code, id, i, c+1)))
+
+	log.Info().Msgf("served %v sequence %s step %d request with X-Request-Id %s code %d", r.URL.Path, key, i, getXRequestId(r), code)
+}
+
+func (s *server) sequencereset(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	n := s.state.sequences.reset(id)
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.WriteHeader(200)
+	w.Write([]byte(fmt.Sprintf(`{"mse6":"Hello from the sequencereset endpoint", "reset":"%d"}`, n)))
+
+	log.Info().Msgf("served %v request with X-Request-Id %s resetting %d sequence counters for id '%s'", r.URL.Path, getXRequestId(r), n, id)
+}
+
+// sequenceScopeKey scopes sequence counters globally, per client address or per header value.
+func sequenceScopeKey(r *http.Request) string {
+	switch r.URL.Query().Get("scope") {
+	case "addr":
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "addr:" + host
+	case "header":
+		h := "X-Request-Id"
+		if len(r.URL.Query().Get("header")) > 0 {
+			h = r.URL.Query().Get("header")
+		}
+		return h + ":" + r.Header.Get(h)
+	default:
+		return "global"
+	}
+}
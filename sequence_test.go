@@ -0,0 +1,77 @@
+package mse6
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSequenceResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().sequence))
+	defer srv.Close()
+
+	//no keepalive, else the client transparently retries the hangup on a reused connection
+	client := http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	want := []int{503, 503, 0, 200, 503}
+	for i, code := range want {
+		res, err := client.Get(srv.URL + "?id=testloop&steps=503,503,hangup,200")
+		if code == 0 {
+			if err == nil {
+				t.Errorf("step %d want hangup, got response %v", i, res.StatusCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("step %d server did not return ok cause %v", i, err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != code {
+			t.Errorf("step %d response status code want %v, got %v", i, code, res.StatusCode)
+		}
+	}
+}
+
+func TestSequenceSticksAndResets(t *testing.T) {
+	s := newServer()
+	srv := httptest.NewServer(http.HandlerFunc(s.sequence))
+	defer srv.Close()
+	reset := httptest.NewServer(http.HandlerFunc(s.sequencereset))
+	defer reset.Close()
+
+	tests := []struct {
+		name  string
+		reqId string
+		code  int
+	}{
+		{"a first", "a", 500},
+		{"a second", "a", 200},
+		{"a sticks", "a", 200},
+		{"b scoped by header", "b", 500},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", srv.URL+"?id=teststick&steps=500,200&mode=stick&scope=header", nil)
+		req.Header.Set("X-Request-Id", tt.reqId)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("%s server did not return ok cause %v", tt.name, err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.code {
+			t.Errorf("%s response status code want %v, got %v", tt.name, tt.code, res.StatusCode)
+		}
+	}
+
+	res, _ := http.Get(reset.URL + "?id=teststick")
+	res.Body.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"?id=teststick&steps=500,200&mode=stick&scope=header", nil)
+	req.Header.Set("X-Request-Id", "a")
+	res, _ = http.DefaultClient.Do(req)
+	res.Body.Close()
+	if res.StatusCode != 500 {
+		t.Errorf("after reset response status code want 500, got %v", res.StatusCode)
+	}
+}
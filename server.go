@@ -13,7 +13,6 @@ const waitDuration = time.Second * 3
 var Version = "v0.5.1"
 var Port int
 var Prefix string
-var rc = 0
 
 const idletimeoutSeconds = 600
 
@@ -23,16 +22,24 @@ type ServerHandler struct {
 	Handler http.HandlerFunc
 }
 
-var handlers []ServerHandler
+// server is a mse6 instance. It owns its routes and all mutable state, so that servers don't share state.
+type server struct {
+	mux   *http.ServeMux
+	state *stateStore
+}
+
+func newServer() *server {
+	return &server{mux: http.NewServeMux(), state: newStateStore()}
+}
 
-func addHandlerFunc(methods []string, pattern string, f http.HandlerFunc) {
+func (s *server) addHandlerFunc(methods []string, pattern string, f http.HandlerFunc) {
 	h := ServerHandler{
 		Methods: methods,
 		Pattern: Prefix + pattern,
 		Handler: f,
 	}
-	handlers = append(handlers, h)
-	http.HandleFunc(h.Pattern, f)
+	s.state.addHandler(h)
+	s.mux.HandleFunc(h.Pattern, f)
 }
 
 func Bootstrap(port int, prefix string, tlsMode bool) {
@@ -44,53 +51,103 @@ func Bootstrap(port int, prefix string, tlsMode bool) {
 	}
 	log.Info().Msgf("mse6 %s starting %s server on port %d with prefix '%s'", Version, mode, Port, Prefix)
 
-	addHandlerFunc([]string{"GET"}, "badcontentlength", badcontentlength)
-	addHandlerFunc([]string{"GET"}, "badgzip", badgzipf)
-	addHandlerFunc([]string{"GET"}, "brotli", brotlif)
-	addHandlerFunc([]string{"CONNECT"}, "connect", connect)
-	addHandlerFunc([]string{"GET"}, "choose", chooseaef)
-	addHandlerFunc([]string{"GET"}, "chunked", chunked)
-	addHandlerFunc([]string{"DELETE"}, "delete", delete)
-	addHandlerFunc([]string{"GET"}, "deflate", deflatef)
-	addHandlerFunc([]string{"GET"}, "echoheader", echoheader)
-	addHandlerFunc([]string{"GET"}, "echoquery", echoquery)
-	addHandlerFunc([]string{"GET"}, "echoport", echoport)
-	addHandlerFunc([]string{"GET"}, "formget", formget)
-	addHandlerFunc([]string{"POST"}, "formpost", formpost)
-	addHandlerFunc([]string{"GET"}, "get", get)
-	addHandlerFunc([]string{"GET", "HEAD"}, "getorhead", getorhead)
-	addHandlerFunc([]string{"GET"}, "gzip", gzipf)
-	addHandlerFunc([]string{"GET"}, "hangupduringheader", hangupConnDuringHeadersSend)
-	addHandlerFunc([]string{"GET"}, "hangupafterheader", hangupConnAfterHeadersSent)
-	addHandlerFunc([]string{"GET"}, "hangupduringbody", hangupConnDuringBodySend)
-	addHandlerFunc([]string{"GET"}, "jwks", jwks)
-	addHandlerFunc([]string{"GET"}, "jwkses256", jwkses256)
-	addHandlerFunc([]string{"GET"}, "jwksbad", jwksbad)
-	addHandlerFunc([]string{"GET"}, "jwksmix", jwksmix)
-	addHandlerFunc([]string{"GET"}, "jwksrotate", jwksrotate)
-	addHandlerFunc([]string{"GET"}, "jwksbadrotate", jwksbadrotate)
-	addHandlerFunc([]string{"GET"}, "nocontentenc", nocontentenc)
-	addHandlerFunc([]string{"OPTIONS"}, "options", options)
-	addHandlerFunc([]string{"PATCH"}, "patch", patch)
-	addHandlerFunc([]string{"POST"}, "post", post)
-	addHandlerFunc([]string{"PUT"}, "put", put)
-	addHandlerFunc([]string{"GET"}, "redirected", redirected)
-	addHandlerFunc([]string{"GET"}, "send", send)
-	addHandlerFunc([]string{"GET"}, "slowheader", slowheader)
-	addHandlerFunc([]string{"GET"}, "slowbody", slowbody)
-	addHandlerFunc([]string{"TRACE"}, "trace", trace)
-	addHandlerFunc([]string{"GET"}, "tiny", tinyidentityf)
-	addHandlerFunc([]string{"GET"}, "tinygzip", tinygzipf)
-	addHandlerFunc([]string{"GET"}, "unknowncontentenc", unknowncontentenc)
-	addHandlerFunc([]string{"GET"}, "websocket", websocket)
+	s := newServer()
+
+	s.addHandlerFunc([]string{"GET"}, "badchallenge", badchallenge)
+	s.addHandlerFunc([]string{"GET"}, "badcookie", badcookie)
+	s.addHandlerFunc([]string{"GET"}, "badcontentlength", badcontentlength)
+	s.addHandlerFunc([]string{"GET"}, "badgzip", badgzipf)
+	s.addHandlerFunc([]string{"GET", "POST", "PUT", "DELETE"}, "basicauth", basicauth)
+	s.addHandlerFunc([]string{"GET", "POST", "PUT", "DELETE"}, "bearerauth", bearerauth)
+	s.addHandlerFunc([]string{"GET"}, "brotli", brotlif)
+	s.addHandlerFunc([]string{"CONNECT"}, "connect", connect)
+	s.addHandlerFunc([]string{"GET"}, "choose", chooseaef)
+	s.addHandlerFunc([]string{"GET"}, "chunked", chunked)
+	s.addHandlerFunc([]string{"GET", "HEAD"}, "cache", s.cache)
+	s.addHandlerFunc([]string{"GET"}, "compressionfault", compressionfault)
+	s.addHandlerFunc([]string{"GET", "HEAD", "PUT"}, "conditional", s.conditional)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "continue", continuef)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "continuedelay", continuedelay)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "continuefinal", continuefinal)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "continuerefuse", continuerefuse)
+	s.addHandlerFunc([]string{"GET"}, "cookies", cookies)
+	s.addHandlerFunc([]string{"OPTIONS", "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}, "cors", cors)
+	s.addHandlerFunc([]string{"DELETE"}, "delete", deletef)
+	s.addHandlerFunc([]string{"GET"}, "deletecookie", deletecookie)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "decompress", decompress)
+	s.addHandlerFunc([]string{"GET"}, "deflate", deflatef)
+	s.addHandlerFunc([]string{"GET", "POST", "PUT", "DELETE"}, "digestauth", digestauth)
+	s.addHandlerFunc([]string{"GET"}, "earlyhints", earlyhints)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "earlyupload", earlyupload)
+	s.addHandlerFunc([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE"}, "echo", echo)
+	s.addHandlerFunc([]string{"POST", "PUT", "PATCH"}, "jsonecho", jsonecho)
+	s.addHandlerFunc([]string{"GET"}, "echoheader", echoheader)
+	s.addHandlerFunc([]string{"GET"}, "echoquery", echoquery)
+	s.addHandlerFunc([]string{"GET"}, "echoport", echoport)
+	s.addHandlerFunc([]string{"GET"}, "formget", formget)
+	s.addHandlerFunc([]string{"POST"}, "formpost", formpost)
+	s.addHandlerFunc([]string{"GET"}, "get", get)
+	s.addHandlerFunc([]string{"GET", "HEAD"}, "getorhead", getorhead)
+	s.addHandlerFunc([]string{"GET"}, "gzip", gzipf)
+	s.addHandlerFunc([]string{"GET"}, "hangupduringheader", hangupConnDuringHeadersSend)
+	s.addHandlerFunc([]string{"GET"}, "hangupafterheader", hangupConnAfterHeadersSent)
+	s.addHandlerFunc([]string{"GET"}, "hangupduringbody", hangupConnDuringBodySend)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "hangupduringupload", hangupduringupload)
+	s.addHandlerFunc([]string{"GET", "POST", "PUT", "PATCH", "DELETE"}, "hmac", hmacsig)
+	s.addHandlerFunc([]string{"GET"}, "informational", informational)
+	s.addHandlerFunc([]string{"GET"}, "jwks", jwks)
+	s.addHandlerFunc([]string{"GET"}, "jwkses256", jwkses256)
+	s.addHandlerFunc([]string{"GET"}, "jwksbad", jwksbad)
+	s.addHandlerFunc([]string{"GET"}, "jwksmix", jwksmix)
+	s.addHandlerFunc([]string{"GET"}, "jwksrotate", s.jwksrotate)
+	s.addHandlerFunc([]string{"GET"}, "jwksbadrotate", s.jwksbadrotate)
+	s.addHandlerFunc([]string{"GET"}, "jwksschedule", s.jwksschedule)
+	s.addHandlerFunc([]string{"GET", "POST"}, "jwksadvance", s.jwksadvance)
+	s.addHandlerFunc([]string{"GET", "POST"}, "jwkssign", s.jwkssign)
+	s.addHandlerFunc([]string{"GET", "POST", "PUT", "DELETE"}, "multichallenge", multichallenge)
+	s.addHandlerFunc([]string{"GET"}, "negotiate", negotiatef)
+	s.addHandlerFunc([]string{"GET"}, "nocontentenc", nocontentenc)
+	s.addHandlerFunc([]string{"GET"}, ".well-known/openid-configuration", oidcdiscovery)
+	s.addHandlerFunc([]string{"GET"}, "oidc/.well-known/openid-configuration", oidcdiscovery)
+	s.addHandlerFunc([]string{"GET"}, "oidc/jwks", s.oidcjwks)
+	s.addHandlerFunc([]string{"POST"}, "oidc/token", s.oidctoken)
+	s.addHandlerFunc([]string{"OPTIONS"}, "options", options)
+	s.addHandlerFunc([]string{"PATCH"}, "patch", patch)
+	s.addHandlerFunc([]string{"POST"}, "post", post)
+	s.addHandlerFunc([]string{"PUT"}, "put", put)
+	s.addHandlerFunc([]string{"GET", "HEAD"}, "range", byterange)
+	s.addHandlerFunc([]string{"GET"}, "ratelimit", s.ratelimit(get))
+	s.addHandlerFunc([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}, "redirect", redirect)
+	s.addHandlerFunc([]string{"GET"}, "redirected", redirected)
+	s.addHandlerFunc([]string{"GET"}, "setcookie", setcookie)
+	s.addHandlerFunc([]string{"GET"}, "send", send)
+	s.addHandlerFunc([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}, "sequence", s.sequence)
+	s.addHandlerFunc([]string{"GET", "HEAD", "POST", "PUT", "DELETE"}, "sigv4/", sigv4)
+	s.addHandlerFunc([]string{"GET", "POST"}, "sequencereset", s.sequencereset)
+	s.addHandlerFunc([]string{"GET"}, "slowheader", slowheader)
+	s.addHandlerFunc([]string{"GET"}, "slowbody", slowbody)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "slowupload", slowupload)
+	s.addHandlerFunc([]string{"GET"}, "sse", sse)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "stallupload", stallupload)
+	s.addHandlerFunc([]string{"GET"}, "stacked", stacked)
+	s.addHandlerFunc([]string{"GET"}, "streamcompressed", streamcompressed)
+	s.addHandlerFunc([]string{"TRACE"}, "trace", trace)
+	s.addHandlerFunc([]string{"GET"}, "tiny", tinyidentityf)
+	s.addHandlerFunc([]string{"GET"}, "tinygzip", tinygzipf)
+	s.addHandlerFunc([]string{"POST", "PUT"}, "upload", s.upload)
+	s.addHandlerFunc([]string{"GET"}, "transfergzip", transfergzip)
+	s.addHandlerFunc([]string{"GET"}, "unknowncontentenc", unknowncontentenc)
+	s.addHandlerFunc([]string{"GET"}, "websocket", websocket)
+	s.addHandlerFunc([]string{"GET"}, "zstd", zstdf)
 
 	//catchall. Matches everything that wasn't previously matched.
-	http.HandleFunc("/", index)
+	s.mux.HandleFunc("/", s.index)
 
 	var err error
 
 	server := &http.Server{
 		Addr:        fmt.Sprintf(":%d", port),
+		Handler:     s.mux,
 		IdleTimeout: time.Duration(idletimeoutSeconds * time.Second),
 	}
 
@@ -109,12 +166,12 @@ func Bootstrap(port int, prefix string, tlsMode bool) {
 	}
 }
 
-func index(w http.ResponseWriter, r *http.Request) {
+func (s *server) index(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Server", "mse6 "+Version)
 	w.Header().Set("Content-Encoding", "identity")
 	w.WriteHeader(200)
 	w.Write([]byte("mse6 " + Version))
-	for _, v := range handlers {
+	for _, v := range s.state.listHandlers() {
 		w.Write([]byte(fmt.Sprintf("\n%v %s", v.Methods, v.Pattern)))
 	}
 
@@ -0,0 +1,333 @@
+package mse6
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"hash"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const signatureSecret = "mse6"
+const signatureHeader = "X-Signature"
+const signatureDateHeader = "X-Date"
+const signatureSkewSeconds = 300
+const sigv4AccessKey = "MSE6ACCESSKEY"
+const sigv4Secret = "mse6"
+const sigv4Algorithm = "AWS4-HMAC-SHA256"
+const sigv4DateFormat = "20060102T150405Z"
+const sigv4SkewSeconds = 900
+
+func signatureConfig(r *http.Request, key string, def string) string {
+	if len(r.URL.Query().Get(key)) > 0 {
+		return r.URL.Query().Get(key)
+	}
+	return def
+}
+
+func hmacHash(algorithm string) func() hash.Hash {
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		return sha1.New
+	case "sha512":
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(h, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	s := sha256.Sum256(b)
+	return hex.EncodeToString(s[:])
+}
+
+// hmacsig verifies a HMAC request signature. The string to sign is the method, the request URI,
+// the value of the date header and the hex encoded SHA-256 of the body, separated by newlines.
+func hmacsig(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	secret := signatureConfig(r, "secret", signatureSecret)
+	algorithm := strings.ToLower(signatureConfig(r, "algorithm", "sha256"))
+	header := signatureConfig(r, "header", signatureHeader)
+	dateHeader := signatureConfig(r, "dateheader", signatureDateHeader)
+
+	date := r.Header.Get(dateHeader)
+	stringToSign := strings.Join([]string{r.Method, r.URL.RequestURI(), date, sha256Hex(body)}, "\n")
+	sum := hmacSum(hmacHash(algorithm), []byte(secret), stringToSign)
+	want := hex.EncodeToString(sum)
+	if r.URL.Query().Get("encoding") == "base64" {
+		want = base64.StdEncoding.EncodeToString(sum)
+	}
+
+	//accept GitHub style algorithm prefixes such as sha256=<signature>
+	got := r.Header.Get(header)
+	if i := strings.Index(got, "="); i > 0 && strings.EqualFold(got[:i], algorithm) {
+		got = got[i+1:]
+	}
+
+	result := map[string]string{
+		"stringToSign":      stringToSign,
+		"algorithm":         "hmac-" + algorithm,
+		"signatureHeader":   header,
+		"signatureProvided": got,
+		"signatureExpected": want,
+	}
+
+	if skewed, skew := signatureSkewed(r, date); skewed {
+		result["error"] = "RequestTimeTooSkewed"
+		result["skewSeconds"] = fmt.Sprintf("%d", skew)
+		sendJSON(w, 403, result)
+		log.Info().Msgf("served %v request with X-Request-Id %s code 403 hmac request time too skewed by %ds", r.URL.Path, getXRequestId(r), skew)
+		return
+	}
+
+	if !hmac.Equal([]byte(got), []byte(want)) {
+		result["error"] = "SignatureDoesNotMatch"
+		sendJSON(w, 401, result)
+		log.Info().Msgf("served %v request with X-Request-Id %s code 401 hmac signature mismatch, string to sign %q", r.URL.Path, getXRequestId(r), stringToSign)
+		return
+	}
+
+	result["mse6"] = "Hello from the hmac endpoint"
+	sendJSON(w, 200, result)
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 hmac signature verified", r.URL.Path, getXRequestId(r))
+}
+
+// signatureSkewed reports whether the date header is too far from server time, or skew=true forces it.
+func signatureSkewed(r *http.Request, date string) (bool, int) {
+	if r.URL.Query().Get("skew") == "true" {
+		return true, parseQueryInt(r, "maxskew", signatureSkewSeconds) + 1
+	}
+	if len(date) == 0 {
+		return false, 0
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, date)
+	}
+	if err != nil {
+		t, err = time.Parse(sigv4DateFormat, date)
+	}
+	if err != nil {
+		return false, 0
+	}
+	skew := int(math.Abs(time.Since(t).Seconds()))
+	return skew > parseQueryInt(r, "maxskew", signatureSkewSeconds), skew
+}
+
+type sigv4Error struct {
+	XMLName               xml.Name `xml:"Error"`
+	Code                  string   `xml:"Code"`
+	Message               string   `xml:"Message"`
+	AWSAccessKeyId        string   `xml:"AWSAccessKeyId,omitempty"`
+	StringToSign          string   `xml:"StringToSign,omitempty"`
+	SignatureProvided     string   `xml:"SignatureProvided,omitempty"`
+	SignatureExpected     string   `xml:"SignatureExpected,omitempty"`
+	CanonicalRequest      string   `xml:"CanonicalRequest,omitempty"`
+	RequestTime           string   `xml:"RequestTime,omitempty"`
+	ServerTime            string   `xml:"ServerTime,omitempty"`
+	MaxAllowedSkewMilli   int      `xml:"MaxAllowedSkewMilliseconds,omitempty"`
+	ClientComputedContent string   `xml:"ClientComputedContentSHA256,omitempty"`
+	S3ComputedContent     string   `xml:"S3ComputedContentSHA256,omitempty"`
+	RequestId             string   `xml:"RequestId"`
+}
+
+func sendSigv4Error(w http.ResponseWriter, r *http.Request, code int, e sigv4Error) {
+	e.RequestId = getXRequestId(r)
+	b, _ := xml.MarshalIndent(e, "", "  ")
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	w.Write([]byte(xml.Header))
+	w.Write(b)
+	log.Info().Msgf("served %v request with X-Request-Id %s code %d sigv4 %s", r.URL.Path, getXRequestId(r), code, e.Code)
+}
+
+// sigv4Encode percent encodes everything but unreserved characters, as required by AWS Signature V4.
+func sigv4Encode(s string, keepSlash bool) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// sigv4CanonicalQuery sorts the parameters by encoded key, then by encoded value. Sorting whole k=v pairs would
+// put a-b=1 before a=2, since '-' sorts before '='.
+func sigv4CanonicalQuery(q url.Values) string {
+	var pairs [][2]string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, [2]string{sigv4Encode(k, false), sigv4Encode(v, false)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	enc := make([]string, len(pairs))
+	for i, p := range pairs {
+		enc[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(enc, "&")
+}
+
+func sigv4HeaderValue(r *http.Request, name string) string {
+	var v string
+	switch name {
+	case "host":
+		v = r.Host
+	case "content-length":
+		v = r.Header.Get("Content-Length")
+		if len(v) == 0 && r.ContentLength >= 0 {
+			v = fmt.Sprintf("%d", r.ContentLength)
+		}
+	default:
+		v = strings.Join(r.Header[http.CanonicalHeaderKey(name)], ",")
+	}
+	return strings.Join(strings.Fields(v), " ")
+}
+
+// sigv4 verifies an AWS Signature Version 4 Authorization header. On mismatch it responds like S3
+// with the canonical request and string to sign it computed.
+func sigv4(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	secret := signatureConfig(r, "secret", sigv4Secret)
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, sigv4Algorithm+" ") {
+		sendSigv4Error(w, r, 403, sigv4Error{Code: "AccessDenied", Message: "Authorization header with " + sigv4Algorithm + " required"})
+		return
+	}
+	params := make(map[string]string)
+	for _, p := range strings.Split(strings.TrimPrefix(auth, sigv4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = kv[1]
+		}
+	}
+	cred := strings.Split(params["Credential"], "/")
+	if len(cred) != 5 || len(params["SignedHeaders"]) == 0 || len(params["Signature"]) == 0 {
+		sendSigv4Error(w, r, 400, sigv4Error{Code: "AuthorizationHeaderMalformed", Message: "the authorization header is malformed: " + auth})
+		return
+	}
+	accessKey, scope := cred[0], strings.Join(cred[1:], "/")
+	if accessKey != signatureConfig(r, "accesskey", sigv4AccessKey) {
+		sendSigv4Error(w, r, 403, sigv4Error{Code: "InvalidAccessKeyId", Message: "The AWS Access Key Id you provided does not exist in our records.", AWSAccessKeyId: accessKey})
+		return
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) == 0 {
+		amzDate = r.Header.Get("Date")
+	}
+	reqTime, err := time.Parse(sigv4DateFormat, amzDate)
+	if err != nil {
+		sendSigv4Error(w, r, 403, sigv4Error{Code: "AccessDenied", Message: "X-Amz-Date header missing or malformed: " + amzDate})
+		return
+	}
+	maxSkew := parseQueryInt(r, "maxskew", sigv4SkewSeconds)
+	if r.URL.Query().Get("skew") == "true" || math.Abs(time.Since(reqTime).Seconds()) > float64(maxSkew) {
+		sendSigv4Error(w, r, 403, sigv4Error{
+			Code:                "RequestTimeTooSkewed",
+			Message:             "The difference between the request time and the current time is too large.",
+			RequestTime:         amzDate,
+			ServerTime:          time.Now().UTC().Format(time.RFC3339),
+			MaxAllowedSkewMilli: maxSkew * 1000,
+		})
+		return
+	}
+
+	payloadHash := sha256Hex(body)
+	if h := r.Header.Get("X-Amz-Content-Sha256"); len(h) > 0 {
+		if len(h) == 64 && h != payloadHash {
+			sendSigv4Error(w, r, 400, sigv4Error{
+				Code:                  "XAmzContentSHA256Mismatch",
+				Message:               "The provided 'x-amz-content-sha256' header does not match what was computed.",
+				ClientComputedContent: h,
+				S3ComputedContent:     payloadHash,
+			})
+			return
+		}
+		payloadHash = h
+	}
+
+	signed := strings.Split(params["SignedHeaders"], ";")
+	var ch bytes.Buffer
+	for _, h := range signed {
+		ch.WriteString(h + ":" + sigv4HeaderValue(r, h) + "\n")
+	}
+
+	service := cred[3]
+	uri := sigv4Encode(r.URL.Path, true)
+	if service != "s3" {
+		uri = sigv4Encode(uri, true)
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		uri,
+		sigv4CanonicalQuery(r.URL.Query()),
+		ch.String(),
+		params["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{sigv4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + secret)
+	for _, s := range cred[1:] {
+		key = hmacSum(sha256.New, key, s)
+	}
+	want := hex.EncodeToString(hmacSum(sha256.New, key, stringToSign))
+
+	if !hmac.Equal([]byte(want), []byte(params["Signature"])) {
+		sendSigv4Error(w, r, 403, sigv4Error{
+			Code:              "SignatureDoesNotMatch",
+			Message:           "The request signature we calculated does not match the signature you provided. Check your key and signing method.",
+			AWSAccessKeyId:    accessKey,
+			StringToSign:      stringToSign,
+			SignatureProvided: params["Signature"],
+			SignatureExpected: want,
+			CanonicalRequest:  canonicalRequest,
+		})
+		return
+	}
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("ETag", `"`+sha256Hex(body)[:32]+`"`)
+	w.WriteHeader(200)
+	if r.Method != "HEAD" {
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"Hello from the sigv4 endpoint", "accessKey":"%s", "scope":"%s", "bytesRead":"%d"}`, accessKey, scope, len(body))))
+	}
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 sigv4 signature verified for access key %s", r.URL.Path, getXRequestId(r), accessKey)
+}
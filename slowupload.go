@@ -0,0 +1,104 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const uploadBytesPerSecond = 1024
+const uploadReadInterval = time.Millisecond * 100
+
+// slowupload reads the request body at a rate of bps bytes per second, applying backpressure to the client.
+func slowupload(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		bps := parseQueryInt(r, "bps", uploadBytesPerSecond)
+		if bps < 1 {
+			bps = uploadBytesPerSecond
+		}
+		chunk := int64(bps) * int64(uploadReadInterval) / int64(time.Second)
+		if chunk < 1 {
+			chunk = 1
+		}
+
+		start := time.Now()
+		var read int64
+		for {
+			n, err := io.CopyN(ioutil.Discard, r.Body, chunk)
+			read += n
+			if err != nil {
+				break
+			}
+			time.Sleep(uploadReadInterval)
+		}
+		defer r.Body.Close()
+		elapsed := time.Since(start)
+
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(200)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"Hello from the slowupload endpoint", "bytesRead":"%d", "bytesPerSecond":"%d", "readMillis":"%d"}`, read, bps, elapsed.Milliseconds())))
+		log.Info().Msgf("served %v request with X-Request-Id %s,%s reading %d bytes from inbound at %d bytes per second in %v", r.URL.Path, getXRequestId(r), expectContinue(r), read, bps, elapsed)
+	} else {
+		send405(w, r)
+	}
+}
+
+// stallupload reads n bytes of the request body, then stops reading for the wait duration before responding.
+func stallupload(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		n := parseQueryInt(r, "n", 0)
+		wd := parseWaitDuration(r)
+		read, _ := io.CopyN(ioutil.Discard, r.Body, int64(n))
+		time.Sleep(wd)
+
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(200)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"Hello from the stallupload endpoint", "bytesRead":"%d", "waitSeconds":"%d"}`, read, int(wd.Seconds()))))
+		log.Info().Msgf("served %v request with X-Request-Id %s,%s reading %d bytes from inbound, then stalled for %d seconds", r.URL.Path, getXRequestId(r), expectContinue(r), read, int(wd.Seconds()))
+	} else {
+		send405(w, r)
+	}
+}
+
+// earlyupload reads n bytes of the request body, then sends a final response before the body is consumed.
+func earlyupload(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		n := parseQueryInt(r, "n", 0)
+		code := parseQueryInt(r, "code", 413)
+		if !(code > 199 && code < 1000) {
+			code = 413
+		}
+		read, _ := io.CopyN(ioutil.Discard, r.Body, int64(n))
+
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(code)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"%d", "bytesRead":"%d"}`, code, read)))
+		log.Info().Msgf("served %v request with X-Request-Id %s,%s code %d after reading %d bytes from inbound", r.URL.Path, getXRequestId(r), expectContinue(r), code, read)
+	} else {
+		send405(w, r)
+	}
+}
+
+// hangupduringupload reads n bytes of the request body, then closes the TCP connection without a response.
+func hangupduringupload(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		n := parseQueryInt(r, "n", 0)
+		read, _ := io.CopyN(ioutil.Discard, r.Body, int64(n))
+
+		hj, _ := w.(http.Hijacker)
+		conn, _, _ := hj.Hijack()
+		conn.Close()
+
+		log.Info().Msgf("served %v incomplete request, initiated hard conn close after reading %d bytes from inbound, X-Request-Id %s", r.URL.Path, read, getXRequestId(r))
+	} else {
+		send405(w, r)
+	}
+}
@@ -0,0 +1,65 @@
+package mse6
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowUploadResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(slowupload))
+	defer srv.Close()
+
+	start := time.Now()
+	res, err := http.Post(srv.URL+"?bps=2048", "application/octet-stream", bytes.NewBuffer(make([]byte, 1024)))
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Errorf("response status code want 200, got %v", res.StatusCode)
+	}
+	if !strings.Contains(string(body), `"bytesRead":"1024"`) {
+		t.Errorf("want 1024 bytes read, got %v", string(body))
+	}
+	if time.Since(start) < time.Millisecond*400 {
+		t.Errorf("upload was read too fast, took %v", time.Since(start))
+	}
+}
+
+func TestEarlyUploadResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(earlyupload))
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"?n=16", "application/octet-stream", bytes.NewBuffer(make([]byte, 1024)))
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != 413 {
+		t.Errorf("response status code want 413, got %v", res.StatusCode)
+	}
+	if !strings.Contains(string(body), `"bytesRead":"16"`) {
+		t.Errorf("want 16 bytes read, got %v", string(body))
+	}
+}
+
+func TestHangupDuringUploadResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(hangupduringupload))
+	defer srv.Close()
+
+	_, err := http.Post(srv.URL+"?n=16", "application/octet-stream", bytes.NewBuffer(make([]byte, 1024)))
+	if err == nil {
+		t.Errorf("server should have hung up during upload")
+	}
+}
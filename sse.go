@@ -0,0 +1,138 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sseMalformed frames an event in one of the ways a client must cope with, cycled per event. Missing the blank line
+// merges the event into the next one, fields without a colon, ids containing NULL and non numeric retry are ignored.
+var sseMalformed = []func(id int, event string, data string) string{
+	func(id int, event string, data string) string {
+		return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n", id, event, data)
+	},
+	func(id int, event string, data string) string {
+		return fmt.Sprintf("id %d\nevent %s\ndata %s\n\n", id, event, data)
+	},
+	func(id int, event string, data string) string {
+		return fmt.Sprintf("id: %d\x00\nevent: %s\ndata: %s\n\n", id, event, data)
+	},
+	func(id int, event string, data string) string {
+		return fmt.Sprintf("retry: soon\nid: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+	},
+}
+
+// sseMaxEvents caps the events per stream, larger n are rejected with 400.
+const sseMaxEvents = 10000
+
+// sseMaxEventId is the largest Last-Event-ID resumed from, so ids counting up from it can't overflow.
+const sseMaxEventId = math.MaxInt32
+
+// lastEventId returns the id sent by a reconnecting client in Last-Event-ID, or 0 if it is invalid or out of range.
+func lastEventId(r *http.Request) int {
+	id, err := strconv.Atoi(strings.TrimSpace(r.Header.Get("Last-Event-ID")))
+	if err != nil || id < 0 || id > sseMaxEventId {
+		return 0
+	}
+	return id
+}
+
+// sse streams n Server-Sent Events with sequential ids, event types cycled from events, every interval milliseconds.
+// The stream starts with a retry field and resumes after the id in Last-Event-ID. fault=disconnect closes the
+// connection after the number of events in after, fault=malformed breaks the event framing, fault=heartbeat sends
+// nothing but comments and fault=drip sends every event one byte at a time, drip milliseconds apart.
+func sse(w http.ResponseWriter, r *http.Request) {
+	n := parseQueryInt(r, "n", 10)
+	if n > sseMaxEvents {
+		sendJSON(w, 400, map[string]interface{}{"mse6": "400", "error": "n exceeds limit", "max": sseMaxEvents})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 n %d exceeds %d sse events", r.URL.Path, getXRequestId(r), n, sseMaxEvents)
+		return
+	}
+	interval := time.Duration(parseQueryInt(r, "interval", 1000)) * time.Millisecond
+	retry := parseQueryInt(r, "retry", 3000)
+	events := queryList(r, "events", "message")
+	if len(events) == 0 {
+		events = []string{"message"}
+	}
+	fault := r.URL.Query().Get("fault")
+	after := parseQueryInt(r, "after", 3)
+	drip := time.Duration(parseQueryInt(r, "drip", 100)) * time.Millisecond
+	last := lastEventId(r)
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(200)
+	f, _ := w.(http.Flusher)
+
+	write := func(s string) bool {
+		if fault != "drip" {
+			_, err := w.Write([]byte(s))
+			f.Flush()
+			return err == nil
+		}
+		for i := 0; i < len(s); i++ {
+			if _, err := w.Write([]byte{s[i]}); err != nil {
+				return false
+			}
+			f.Flush()
+			if !sseWait(r, drip) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if retry > 0 && !write(fmt.Sprintf("retry: %d\n\n", retry)) {
+		return
+	}
+	for i := 1; i <= n; i++ {
+		id := last + i
+		if fault == "disconnect" && i > after {
+			hj, _ := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+			log.Info().Msgf("served %v request with X-Request-Id %s sse hard conn close after event id %d", r.URL.Path, getXRequestId(r), id-1)
+			return
+		}
+
+		var frame string
+		event := events[(id-1)%len(events)]
+		data := fmt.Sprintf(`{"mse6":"Hello from the sse endpoint", "id":%d, "event":"%s"}`, id, event)
+		switch fault {
+		case "heartbeat":
+			frame = fmt.Sprintf(": heartbeat %d\n\n", i)
+		case "malformed":
+			frame = sseMalformed[(i-1)%len(sseMalformed)](id, event, data)
+		default:
+			frame = fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+		}
+		if !write(frame) {
+			log.Info().Msgf("aborted %v request with X-Request-Id %s sse after %d events", r.URL.Path, getXRequestId(r), i-1)
+			return
+		}
+		if i < n && !sseWait(r, interval) {
+			log.Info().Msgf("aborted %v request with X-Request-Id %s sse after %d events, client gone", r.URL.Path, getXRequestId(r), i)
+			return
+		}
+	}
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 sent %d sse events after Last-Event-ID %d fault %s", r.URL.Path, getXRequestId(r), n, last, fault)
+}
+
+// sseWait sleeps for d and returns false if the client went away in the meantime.
+func sseWait(r *http.Request, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
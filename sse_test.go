@@ -0,0 +1,100 @@
+package mse6
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getSse(t *testing.T, url string, lastEventId string) (string, error) {
+	req, _ := http.NewRequest("GET", url, nil)
+	if len(lastEventId) > 0 {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return "", err
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("want Content-Type text/event-stream, got %v", ct)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	return string(b), err
+}
+
+func TestSseEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(sse))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		query       string
+		lastEventId string
+		want        []string
+		notWant     []string
+	}{
+		{"default", "?n=3&interval=10", "", []string{"retry: 3000\n\n", "id: 1\nevent: message\n", "id: 3\n"}, []string{"id: 4\n"}},
+		{"event types", "?n=3&interval=10&events=tick,update&retry=0", "", []string{"id: 1\nevent: tick\n", "id: 2\nevent: update\n", "id: 3\nevent: tick\n"}, []string{"retry:"}},
+		{"resume", "?n=2&interval=10", "41", []string{"id: 42\n", "id: 43\n"}, []string{"id: 1\n", "id: 44\n"}},
+		{"resume out of range", "?n=2&interval=10", "9223372036854775807", []string{"id: 1\n", "id: 2\n"}, []string{"id: -"}},
+		{"empty event types", "?n=2&interval=10&events=,", "", []string{"id: 1\nevent: message\n", "id: 2\nevent: message\n"}, nil},
+		{"heartbeat", "?n=2&interval=10&fault=heartbeat", "", []string{": heartbeat 1\n\n", ": heartbeat 2\n\n"}, []string{"id:", "data:"}},
+		{"malformed", "?n=4&interval=10&fault=malformed", "", []string{"data: {\"mse6\":\"Hello from the sse endpoint\", \"id\":1, \"event\":\"message\"}\nid 2\n", "id: 3\x00\n", "retry: soon\n"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := getSse(t, srv.URL+"/sse"+tt.query, tt.lastEventId)
+			if err != nil {
+				t.Errorf("want complete stream, got %v", err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(b, w) {
+					t.Errorf("want %q in stream, got %q", w, b)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(b, w) {
+					t.Errorf("don't want %q in stream, got %q", w, b)
+				}
+			}
+		})
+	}
+}
+
+func TestSseDisconnect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(sse))
+	defer srv.Close()
+
+	b, err := getSse(t, srv.URL+"/sse?n=5&interval=10&fault=disconnect&after=2", "")
+	if err == nil {
+		t.Errorf("want error for incomplete stream")
+	}
+	if !strings.Contains(b, "id: 2\n") || strings.Contains(b, "id: 3\n") {
+		t.Errorf("want 2 events before disconnect, got %q", b)
+	}
+}
+
+func TestSseDrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(sse))
+	defer srv.Close()
+
+	start := time.Now()
+	b, _ := getSse(t, srv.URL+"/sse?n=1&retry=0&fault=drip&drip=1", "")
+	if !strings.HasPrefix(b, "id: 1\n") || time.Since(start) < time.Duration(len(b))*time.Millisecond {
+		t.Errorf("want event dripped over %d ms, got %q in %v", len(b), b, time.Since(start))
+	}
+}
+
+func TestSseCapsEvents(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse(w, httptest.NewRequest("GET", "/sse?n=1000000000", nil))
+	if w.Code != 400 {
+		t.Errorf("response status code want 400, got %v", w.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+)
+
+// stackedEncodings parses the comma separated encodings parameter in the order they are applied.
+func stackedEncodings(r *http.Request) []string {
+	v := r.URL.Query().Get("encodings")
+	if len(v) == 0 {
+		v = "gzip,br"
+	}
+	encs := make([]string, 0)
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); len(e) > 0 {
+			encs = append(encs, e)
+		}
+	}
+	return encs
+}
+
+// stacked sends a body with multiple content encodings applied in the order listed in encodings,
+// i.e. Content-Encoding: gzip, br was gzipped first and must be decoded with brotli first. Unknown encodings are
+// rejected with 400, so the header never advertises an encoding that wasn't applied.
+func stacked(w http.ResponseWriter, r *http.Request) {
+	encs := stackedEncodings(r)
+	for _, e := range encs {
+		if _, ok := codecs.get(e); !ok {
+			sendJSON(w, 400, map[string]interface{}{"mse6": "400", "error": "unknown encoding " + e, "supported": codecs.list()})
+			log.Info().Msgf("served %v request with X-Request-Id %s response code 400 for unknown encoding %s", r.URL.Path, getXRequestId(r), e)
+			return
+		}
+	}
+	b := []byte(`{"mse6":"Hello from the stacked endpoint"}`)
+	for _, e := range encs {
+		var err error
+		if b, err = encodeBytes(e, b); err != nil {
+			sendEncodingError(w, r, e, err)
+			return
+		}
+	}
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", strings.Join(encs, ", "))
+	w.WriteHeader(200)
+	w.Write(b)
+
+	log.Info().Msgf("served %v request with X-Request-Id %s Content-Encoding %s", r.URL.Path, getXRequestId(r), strings.Join(encs, ", "))
+}
+
+// transfergzip sends a gzip transfer coded, chunked body. net/http only writes chunked transfer coding,
+// so the response is written to the hijacked connection.
+func transfergzip(w http.ResponseWriter, r *http.Request) {
+	b, err := gzipenc([]byte(`{"mse6":"Hello from the transfergzip endpoint"}`))
+	if err != nil {
+		sendEncodingError(w, r, "gzip", err)
+		return
+	}
+
+	hj, _ := w.(http.Hijacker)
+	conn, bufrw, _ := hj.Hijack()
+	defer conn.Close()
+
+	bufrw.WriteString("HTTP/1.1 200 OK\r\n")
+	bufrw.WriteString(fmt.Sprintf("Server: mse6 %s\r\n", Version))
+	bufrw.WriteString("Content-Type: application/json\r\n")
+	bufrw.WriteString("Transfer-Encoding: gzip, chunked\r\n")
+	bufrw.WriteString("Connection: close\r\n\r\n")
+	half := len(b) / 2
+	for _, c := range [][]byte{b[:half], b[half:]} {
+		bufrw.WriteString(fmt.Sprintf("%x\r\n", len(c)))
+		bufrw.Write(c)
+		bufrw.WriteString("\r\n")
+	}
+	bufrw.WriteString("0\r\n\r\n")
+	bufrw.Flush()
+
+	log.Info().Msgf("served %v request with X-Request-Id %s Transfer-Encoding gzip, chunked", r.URL.Path, getXRequestId(r))
+}
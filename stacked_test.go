@@ -0,0 +1,111 @@
+package mse6
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+)
+
+func TestStackedResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(stacked))
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		ce    string
+	}{
+		{"default", "", "gzip, br"},
+		{"three layers", "?encodings=deflate,zstd,gzip", "deflate, zstd, gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+tt.query, nil)
+			req.Header.Set("Accept-Encoding", "identity")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.Header.Get("Content-Encoding") != tt.ce {
+				t.Errorf("Content-Encoding want %v, got %v", tt.ce, res.Header.Get("Content-Encoding"))
+			}
+			encs := strings.Split(tt.ce, ", ")
+			for i := len(encs) - 1; i >= 0; i-- {
+				if b, err = decodeBytes(encs[i], b); err != nil {
+					t.Errorf("unable to decode %s cause %v", encs[i], err)
+					return
+				}
+			}
+			if !strings.Contains(string(b), "stacked endpoint") {
+				t.Errorf("want decoded body, got %s", b)
+			}
+		})
+	}
+}
+
+func TestStackedRejectsUnknownEncoding(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(stacked))
+	defer srv.Close()
+
+	for _, q := range []string{"?encodings=gzip,compress", "?encodings=identity"} {
+		res, err := http.Get(srv.URL + q)
+		if err != nil {
+			t.Errorf("server did not return ok cause %v", err)
+			return
+		}
+		res.Body.Close()
+		if res.StatusCode != 400 {
+			t.Errorf("%s response status code want 400, got %v", q, res.StatusCode)
+		}
+		if ce := res.Header.Get("Content-Encoding"); strings.Contains(ce, "compress") || strings.Contains(ce, "gzip") {
+			t.Errorf("%s want no unapplied encoding advertised, got %v", q, ce)
+		}
+	}
+}
+
+func TestTransferGzipResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(transfergzip))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Errorf("unable to connect cause %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte("GET /transfergzip HTTP/1.1\r\nHost: mse6\r\nTE: gzip\r\n\r\n"))
+
+	br := bufio.NewReader(conn)
+	te := ""
+	for {
+		l, _ := br.ReadString('\n')
+		if l == "\r\n" || len(l) == 0 {
+			break
+		}
+		if strings.HasPrefix(l, "Transfer-Encoding:") {
+			te = strings.TrimSpace(strings.TrimPrefix(l, "Transfer-Encoding:"))
+		}
+	}
+	if te != "gzip, chunked" {
+		t.Errorf("Transfer-Encoding want gzip, chunked, got %v", te)
+	}
+	gz, err := gzip.NewReader(httputil.NewChunkedReader(br))
+	if err != nil {
+		t.Errorf("body is not gzip cause %v", err)
+		return
+	}
+	b, _ := ioutil.ReadAll(gz)
+	if !strings.Contains(string(b), "transfergzip endpoint") {
+		t.Errorf("want decoded body, got %s", b)
+	}
+}
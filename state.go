@@ -0,0 +1,47 @@
+package mse6
+
+import "sync"
+
+// stateStore owns all mutable state of a server. Handlers run on concurrent goroutines,
+// so every field is either guarded by mu or synchronises access itself. counters and cache are internal counters of
+// stateful routes, kept apart from sequences so /sequencereset and user chosen ids can't touch them.
+type stateStore struct {
+	mu          sync.RWMutex
+	handlers    []ServerHandler
+	limiter     *rateLimiter
+	sequences   *sequenceStore
+	counters    *sequenceStore
+	cache       *sequenceStore
+	oidc        *oidcProvider
+	rotation    *jwksRotation
+	uploads     *uploadStore
+	conditional *conditionalStore
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{
+		limiter:     newRateLimiter(),
+		sequences:   &sequenceStore{counters: make(map[string]int)},
+		counters:    &sequenceStore{counters: make(map[string]int)},
+		cache:       &sequenceStore{counters: make(map[string]int), max: cacheMaxCounters},
+		oidc:        &oidcProvider{},
+		rotation:    &jwksRotation{},
+		uploads:     &uploadStore{sessions: make(map[string]*uploadSession)},
+		conditional: &conditionalStore{resources: make(map[string]conditionalResource)},
+	}
+}
+
+func (s *stateStore) addHandler(h ServerHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, h)
+}
+
+// listHandlers returns a copy of the registered handlers that is safe to range over.
+func (s *stateStore) listHandlers() []ServerHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hs := make([]ServerHandler, len(s.handlers))
+	copy(hs, s.handlers)
+	return hs
+}
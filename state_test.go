@@ -0,0 +1,111 @@
+package mse6
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestStatefulHandlersConcurrently hammers all stateful routes in parallel. Run with go test -race.
+func TestStatefulHandlersConcurrently(t *testing.T) {
+	s := newServer()
+	s.mux.HandleFunc("/jwksbadrotate", s.jwksbadrotate)
+	s.mux.HandleFunc("/jwksrotate", s.jwksrotate)
+	s.mux.HandleFunc("/jwksschedule", s.jwksschedule)
+	s.mux.HandleFunc("/jwksadvance", s.jwksadvance)
+	s.mux.HandleFunc("/jwkssign", s.jwkssign)
+	s.mux.HandleFunc("/oidc/jwks", s.oidcjwks)
+	s.mux.HandleFunc("/oidc/token", s.oidctoken)
+	s.mux.HandleFunc("/cache", s.cache)
+	s.mux.HandleFunc("/conditional", s.conditional)
+	s.mux.HandleFunc("/upload", s.upload)
+	s.mux.HandleFunc("/sequence", s.sequence)
+	s.mux.HandleFunc("/ratelimit", s.ratelimit(get))
+	s.mux.HandleFunc("/", s.index)
+	srv := httptest.NewServer(s.mux)
+	defer srv.Close()
+
+	token := url.Values{"grant_type": {"client_credentials"}, "client_id": {"mse6"}, "client_secret": {"mse6"}}.Encode()
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		header map[string]string
+	}{
+		{"GET", "/jwksbadrotate", "", nil},
+		{"GET", "/jwksrotate", "", nil},
+		{"GET", "/jwksschedule", "", nil},
+		{"POST", "/jwksadvance?n=1", "", nil},
+		{"POST", "/jwkssign", "", nil},
+		{"GET", "/oidc/jwks", "", nil},
+		{"POST", "/oidc/token", token, map[string]string{"Content-Type": "application/x-www-form-urlencoded"}},
+		{"GET", "/cache?id=testconcurrent", "", nil},
+		{"GET", "/conditional?id=testconcurrent", "", nil},
+		{"PUT", "/conditional?id=testconcurrent", "{}", nil},
+		{"PUT", "/upload?id=testconcurrent", "mse6", map[string]string{"Content-Range": "bytes 0-3/8"}},
+		{"GET", "/sequence?id=testconcurrent&steps=200,201,202", "", nil},
+		{"GET", "/ratelimit?limit=1000&key=header", "", nil},
+		{"GET", "/", "", nil},
+	}
+
+	n := 25
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		for _, rq := range requests {
+			wg.Add(1)
+			go func(method string, path string, body string, header map[string]string) {
+				defer wg.Done()
+				req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+				for k, v := range header {
+					req.Header.Set(k, v)
+				}
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Errorf("server did not return ok cause %v", err)
+					return
+				}
+				ioutil.ReadAll(res.Body)
+				res.Body.Close()
+				if res.StatusCode >= 500 {
+					t.Errorf("%s %s response status code want < 500, got %v", method, path, res.StatusCode)
+				}
+			}(rq.method, rq.path, rq.body, rq.header)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.state.addHandler(ServerHandler{Methods: []string{"GET"}, Pattern: "/testconcurrent", Handler: get})
+		}()
+	}
+	wg.Wait()
+
+	if c := s.state.counters.next("jwksbadrotate|global"); c != n {
+		t.Errorf("jwksbadrotate counter want %d, got %d", n, c)
+	}
+	if c := s.state.sequences.next("testconcurrent|global"); c != n {
+		t.Errorf("sequence counter want %d, got %d", n, c)
+	}
+	if c := s.state.cache.next("testconcurrent|hits"); c != n {
+		t.Errorf("cache counter want %d, got %d", n, c)
+	}
+	if c := s.state.conditional.get("testconcurrent"); c.version != n+1 {
+		t.Errorf("conditional version want %d, got %d", n+1, c.version)
+	}
+}
+
+func TestServersDoNotShareState(t *testing.T) {
+	a, b := newServer(), newServer()
+	a.state.sequences.next("testisolation|global")
+	a.state.addHandler(ServerHandler{Methods: []string{"GET"}, Pattern: "/testisolation", Handler: get})
+
+	if c := b.state.sequences.next("testisolation|global"); c != 0 {
+		t.Errorf("sequence counter leaked between servers, want 0, got %d", c)
+	}
+	if len(b.state.listHandlers()) != 0 {
+		t.Errorf("handlers leaked between servers, got %v", b.state.listHandlers())
+	}
+}
@@ -0,0 +1,73 @@
+package mse6
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"time"
+)
+
+type codecFlusher interface {
+	Flush() error
+}
+
+// streamcompressed streams n NDJSON lines, or SSE events with format=sse, through a codec=gzip|br|deflate|zstd
+// encoder. Each event is flushed as a complete compressed block, followed by a wait of interval milliseconds,
+// so clients can decode every event as it arrives.
+func streamcompressed(w http.ResponseWriter, r *http.Request) {
+	codec := r.URL.Query().Get("codec")
+	if len(codec) == 0 {
+		codec = "gzip"
+	}
+	c, ok := codecs.get(codec)
+	if !ok {
+		w.Header().Set("Server", "mse6 "+Version)
+		w.Header().Set("Content-Encoding", "identity")
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf(`{"mse6":"400", "error":"unsupported codec %s"}`, codec)))
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 unsupported codec %s", r.URL.Path, getXRequestId(r), codec)
+		return
+	}
+	n := parseQueryInt(r, "n", 10)
+	interval := time.Duration(parseQueryInt(r, "interval", 1000)) * time.Millisecond
+	sse := r.URL.Query().Get("format") == "sse"
+
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", codec)
+	w.Header().Set("Cache-Control", "no-cache")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	cw, err := c.Encode(w)
+	if err != nil {
+		sendEncodingError(w, r, codec, err)
+		return
+	}
+	w.WriteHeader(200)
+
+	f, _ := w.(http.Flusher)
+	for i := 1; i <= n; i++ {
+		event := fmt.Sprintf(`{"mse6":"Hello from the streamcompressed endpoint", "n":%d, "of":%d}`, i, n)
+		if sse {
+			event = fmt.Sprintf("id: %d\ndata: %s\n\n", i, event)
+		} else {
+			event += "\n"
+		}
+		_, err := cw.Write([]byte(event))
+		if fl, ok := cw.(codecFlusher); ok && err == nil {
+			err = fl.Flush()
+		}
+		if err != nil {
+			log.Info().Msgf("aborted %v request with X-Request-Id %s after %d events, cause: %v", r.URL.Path, getXRequestId(r), i-1, err)
+			return
+		}
+		f.Flush()
+		if i < n {
+			time.Sleep(interval)
+		}
+	}
+	cw.Close()
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 streamed %d %s events", r.URL.Path, getXRequestId(r), n, codec)
+}
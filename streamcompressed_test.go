@@ -0,0 +1,63 @@
+package mse6
+
+import (
+	"bufio"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamCompressedDeliversIncrementally(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(streamcompressed))
+	defer srv.Close()
+
+	decoders := map[string]func(io.Reader) io.Reader{
+		"gzip": func(r io.Reader) io.Reader {
+			gz, _ := gzip.NewReader(r)
+			return gz
+		},
+		"deflate": func(r io.Reader) io.Reader { return flate.NewReader(r) },
+		"br":      func(r io.Reader) io.Reader { return brotli.NewReader(r) },
+		"zstd": func(r io.Reader) io.Reader {
+			d, _ := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			return d
+		},
+	}
+
+	for codec, decoder := range decoders {
+		t.Run(codec, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", srv.URL+"/streamcompressed?n=3&interval=300&codec="+codec, nil)
+			req.Header.Set("Accept-Encoding", codec)
+			res, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			defer res.Body.Close()
+
+			start := time.Now()
+			lines := bufio.NewReader(decoder(res.Body))
+			for i := 1; i <= 3; i++ {
+				l, err := lines.ReadString('\n')
+				if err != nil {
+					t.Errorf("event %d not decoded cause %v", i, err)
+					return
+				}
+				if !strings.Contains(l, `"n":`) {
+					t.Errorf("event %d want NDJSON line, got %v", i, l)
+				}
+				//the first event must be decodable before the server waits for the next one.
+				if i == 1 && time.Since(start) > 250*time.Millisecond {
+					t.Errorf("first event was buffered for %v", time.Since(start))
+				}
+			}
+		})
+	}
+}
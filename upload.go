@@ -0,0 +1,324 @@
+package mse6
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type uploadPart struct {
+	Name        string `json:"name"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+type uploadResult struct {
+	ContentType string       `json:"contentType"`
+	Size        int64        `json:"size"`
+	SHA256      string       `json:"sha256,omitempty"`
+	Parts       []uploadPart `json:"parts,omitempty"`
+}
+
+const uploadSessionTimeout = time.Hour
+const uploadSweepInterval = time.Minute
+
+type uploadSession struct {
+	mu     sync.Mutex
+	offset int64
+	hash   hash.Hash
+	seen   time.Time
+}
+
+// append hashes a chunk of exactly size bytes. The offset and hash are only updated if the whole chunk was read,
+// so a failed or short chunk can be sent again.
+func (u *uploadSession) append(body io.Reader, size int64) error {
+	state, err := u.hash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	if err = h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+		return err
+	}
+	n, err := io.Copy(h, io.LimitReader(body, size))
+	if err == nil && n != size {
+		err = fmt.Errorf("chunk is short, received %d of %d bytes", n, size)
+	}
+	if err != nil {
+		return err
+	}
+	u.offset += n
+	u.hash = h
+	return nil
+}
+
+type uploadStore struct {
+	mu        sync.Mutex
+	sessions  map[string]*uploadSession
+	lastSweep time.Time
+}
+
+// sweep evicts sessions that received no chunk for uploadSessionTimeout, i.e. abandoned uploads.
+func (s *uploadStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < uploadSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for id, u := range s.sessions {
+		if now.Sub(u.seen) >= uploadSessionTimeout {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// session returns the resumable upload session for id, creating it on first use.
+func (s *uploadStore) session(id string, now time.Time) *uploadSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	u, ok := s.sessions[id]
+	if !ok {
+		u = &uploadSession{hash: sha256.New()}
+		s.sessions[id] = u
+	}
+	u.seen = now
+	return u
+}
+
+func (s *uploadStore) finish(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// errUploadTooLarge is returned by the limited reader once more than max bytes were read.
+var errUploadTooLarge = fmt.Errorf("upload exceeds size limit")
+
+type uploadLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errUploadTooLarge
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
+
+func hashCopy(r io.Reader) (int64, string, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	return n, hex.EncodeToString(h.Sum(nil)), err
+}
+
+func sendUploadTooLarge(w http.ResponseWriter, r *http.Request, max int64) {
+	w.Header().Set("Connection", "close")
+	sendJSON(w, 413, map[string]interface{}{"mse6": "413", "max": max})
+	log.Info().Msgf("served %v request with X-Request-Id %s code 413 upload exceeds %d bytes", r.URL.Path, getXRequestId(r), max)
+}
+
+// upload reports the size and SHA-256 of the request body, or of each part of a multipart/form-data body.
+// max limits the body size with 413, Content-Range requests are assembled into a resumable upload keyed by id
+// and fault=malformed responds with a broken multipart echo of the parts.
+func (s *server) upload(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	max := int64(parseQueryInt(r, "max", 0))
+	if max > 0 && r.ContentLength > max {
+		sendUploadTooLarge(w, r, max)
+		return
+	}
+	body := io.Reader(r.Body)
+	if max > 0 {
+		body = &uploadLimitReader{r: r.Body, remaining: max}
+	}
+
+	if cr := r.Header.Get("Content-Range"); len(cr) > 0 {
+		s.resumableUpload(w, r, body, cr, max)
+		return
+	}
+
+	ct := r.Header.Get("Content-Type")
+	result := uploadResult{ContentType: ct}
+	if mt, params, _ := mime.ParseMediaType(ct); mt == "multipart/form-data" {
+		if len(params["boundary"]) == 0 {
+			sendJSON(w, 400, map[string]string{"mse6": "400", "error": "multipart/form-data without boundary"})
+			log.Info().Msgf("served %v request with X-Request-Id %s code 400 multipart body without boundary", r.URL.Path, getXRequestId(r))
+			return
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		result.Parts = make([]uploadPart, 0)
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err == nil {
+				var n int64
+				var sum string
+				n, sum, err = hashCopy(p)
+				result.Parts = append(result.Parts, uploadPart{
+					Name:        p.FormName(),
+					Filename:    p.FileName(),
+					ContentType: p.Header.Get("Content-Type"),
+					Size:        n,
+					SHA256:      sum,
+				})
+				result.Size += n
+			}
+			if err == errUploadTooLarge {
+				sendUploadTooLarge(w, r, max)
+				return
+			}
+			if err != nil {
+				sendJSON(w, 400, map[string]string{"mse6": "400", "error": err.Error()})
+				log.Info().Msgf("served %v request with X-Request-Id %s code 400 malformed multipart body, cause: %v", r.URL.Path, getXRequestId(r), err)
+				return
+			}
+		}
+	} else {
+		n, sum, err := hashCopy(body)
+		if err == errUploadTooLarge {
+			sendUploadTooLarge(w, r, max)
+			return
+		}
+		if err != nil {
+			sendJSON(w, 400, map[string]string{"mse6": "400", "error": err.Error()})
+			log.Info().Msgf("served %v request with X-Request-Id %s code 400 incomplete body after %d bytes, cause: %v", r.URL.Path, getXRequestId(r), n, err)
+			return
+		}
+		result.Size, result.SHA256 = n, sum
+	}
+
+	if r.URL.Query().Get("fault") == "malformed" {
+		sendMalformedMultipart(w, r, result)
+		return
+	}
+	sendJSON(w, 200, result)
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 received %d bytes in %d parts", r.URL.Path, getXRequestId(r), result.Size, len(result.Parts))
+}
+
+// parseContentRange parses "bytes first-last/total" and "bytes */total". total is -1 if unknown, first is -1 for
+// a status query.
+func parseContentRange(cr string) (int64, int64, int64, bool) {
+	if !strings.HasPrefix(cr, "bytes ") {
+		return 0, 0, 0, false
+	}
+	rt := strings.SplitN(strings.TrimPrefix(cr, "bytes "), "/", 2)
+	if len(rt) != 2 {
+		return 0, 0, 0, false
+	}
+	total := int64(-1)
+	if rt[1] != "*" {
+		t, err := strconv.ParseInt(rt[1], 10, 64)
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		total = t
+	}
+	if rt[0] == "*" {
+		return -1, -1, total, true
+	}
+	fl := strings.SplitN(rt[0], "-", 2)
+	if len(fl) != 2 {
+		return 0, 0, 0, false
+	}
+	first, err1 := strconv.ParseInt(fl[0], 10, 64)
+	last, err2 := strconv.ParseInt(fl[1], 10, 64)
+	if err1 != nil || err2 != nil || last < first || (total >= 0 && last >= total) {
+		return 0, 0, 0, false
+	}
+	return first, last, total, true
+}
+
+// resumableUpload appends a Content-Range chunk to the upload session of id. Incomplete uploads are answered with
+// 308 and a Range header of the bytes received so far, chunks that don't continue at that offset are ignored.
+// Chunks that fail or arrive short are rejected and leave the session unchanged.
+func (s *server) resumableUpload(w http.ResponseWriter, r *http.Request, body io.Reader, cr string, max int64) {
+	id := r.URL.Query().Get("id")
+	if len(id) == 0 {
+		sendJSON(w, 400, map[string]string{"mse6": "400", "error": "resumable upload without id"})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 resumable upload without id", r.URL.Path, getXRequestId(r))
+		return
+	}
+	first, last, total, ok := parseContentRange(cr)
+	if !ok {
+		sendJSON(w, 400, map[string]string{"mse6": "400", "error": "malformed Content-Range " + cr})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 malformed Content-Range %s", r.URL.Path, getXRequestId(r), cr)
+		return
+	}
+	if max > 0 && (total > max || last >= max) {
+		sendUploadTooLarge(w, r, max)
+		return
+	}
+
+	u := s.state.uploads.session(id, time.Now())
+	u.mu.Lock()
+	var err error
+	if first == u.offset {
+		err = u.append(body, last-first+1)
+	}
+	offset := u.offset
+	sum := hex.EncodeToString(u.hash.Sum(nil))
+	u.mu.Unlock()
+
+	if err == errUploadTooLarge {
+		sendUploadTooLarge(w, r, max)
+		return
+	}
+	if err != nil {
+		sendJSON(w, 400, map[string]string{"mse6": "400", "error": err.Error()})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 400 upload %s chunk %d-%d rejected, cause: %v", r.URL.Path, getXRequestId(r), id, first, last, err)
+		return
+	}
+
+	if total >= 0 && offset == total {
+		s.state.uploads.finish(id)
+		sendJSON(w, 200, uploadResult{ContentType: r.Header.Get("Content-Type"), Size: offset, SHA256: sum})
+		log.Info().Msgf("served %v request with X-Request-Id %s code 200 completed upload %s with %d bytes", r.URL.Path, getXRequestId(r), id, offset)
+		return
+	}
+
+	if offset > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", offset-1))
+	}
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.WriteHeader(308)
+	log.Info().Msgf("served %v request with X-Request-Id %s code 308 upload %s received %d bytes", r.URL.Path, getXRequestId(r), id, offset)
+}
+
+// sendMalformedMultipart echoes the parts as a multipart response whose boundary doesn't match the
+// Content-Type, with an unterminated part header and without the closing delimiter.
+func sendMalformedMultipart(w http.ResponseWriter, r *http.Request, result uploadResult) {
+	w.Header().Set("Server", "mse6 "+Version)
+	w.Header().Set("Content-Encoding", "identity")
+	w.Header().Set("Content-Type", "multipart/mixed; boundary=mse6boundary")
+	w.WriteHeader(200)
+	b := &strings.Builder{}
+	for _, p := range result.Parts {
+		fmt.Fprintf(b, "--mse6boundry\r\nContent-Disposition: form-data; name=\"%s\"; filename=\"%s\r\n", p.Name, p.Filename)
+		fmt.Fprintf(b, "Content-Type %s\r\n\r\nsize=%d sha256=%s\r\n", p.ContentType, p.Size, p.SHA256)
+	}
+	fmt.Fprintf(b, "--mse6boundary\r\nContent-Length: 1024\r\n\r\nsize=%d sha256=%s", result.Size, result.SHA256)
+	w.Write([]byte(b.String()))
+	log.Info().Msgf("served %v request with X-Request-Id %s code 200 malformed multipart echo of %d parts", r.URL.Path, getXRequestId(r), len(result.Parts))
+}
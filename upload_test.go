@@ -0,0 +1,214 @@
+package mse6
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestUploadMultipartResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().upload))
+	defer srv.Close()
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("field", "value")
+	fw, _ := mw.CreateFormFile("file", "mse6.bin")
+	fw.Write(bytes.Repeat([]byte{0x01}, 1000))
+	mw.Close()
+
+	res, err := http.Post(srv.URL, mw.FormDataContentType(), bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	var result uploadResult
+	json.NewDecoder(res.Body).Decode(&result)
+	res.Body.Close()
+
+	want := sha256.Sum256(bytes.Repeat([]byte{0x01}, 1000))
+	if len(result.Parts) != 2 {
+		t.Errorf("want 2 parts, got %v", result.Parts)
+		return
+	}
+	if result.Parts[0].Name != "field" || result.Parts[0].Size != 5 {
+		t.Errorf("form field part wrong, got %v", result.Parts[0])
+	}
+	if result.Parts[1].Filename != "mse6.bin" || result.Parts[1].Size != 1000 || result.Parts[1].SHA256 != hex.EncodeToString(want[:]) {
+		t.Errorf("file part wrong, got %v", result.Parts[1])
+	}
+}
+
+func TestUploadRawResponds(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().upload))
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		query string
+		size  int
+		code  int
+	}{
+		{"raw", "", 4096, 200},
+		{"within limit", "?max=4096", 4096, 200},
+		{"too large", "?max=1024", 4096, 413},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.Post(srv.URL+tt.query, "application/octet-stream", bytes.NewReader(make([]byte, tt.size)))
+			if err != nil {
+				t.Errorf("server did not return ok cause %v", err)
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if res.StatusCode != tt.code {
+				t.Errorf("response status code want %v, got %v: %s", tt.code, res.StatusCode, b)
+			}
+		})
+	}
+}
+
+func TestUploadResumable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().upload))
+	defer srv.Close()
+
+	data := bytes.Repeat([]byte("mse6"), 256)
+	put := func(first int, last int) *http.Response {
+		req, _ := http.NewRequest("PUT", srv.URL+"/upload?id=TestUploadResumable", bytes.NewReader(data[first:last+1]))
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(data)))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("server did not return ok cause %v", err)
+		}
+		return res
+	}
+
+	chunks := []struct {
+		first int
+		last  int
+		code  int
+		rng   string
+	}{
+		{0, 511, 308, "bytes=0-511"},
+		{600, 1023, 308, "bytes=0-511"},
+		{512, 1023, 200, ""},
+	}
+	for _, c := range chunks {
+		res := put(c.first, c.last)
+		b, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != c.code {
+			t.Errorf("chunk %d-%d status code want %v, got %v", c.first, c.last, c.code, res.StatusCode)
+		}
+		if res.Header.Get("Range") != c.rng {
+			t.Errorf("chunk %d-%d Range want %v, got %v", c.first, c.last, c.rng, res.Header.Get("Range"))
+		}
+		if c.code == 200 {
+			want := sha256.Sum256(data)
+			if !strings.Contains(string(b), hex.EncodeToString(want[:])) {
+				t.Errorf("want sha256 of assembled upload, got %s", b)
+			}
+		}
+	}
+}
+
+func TestUploadMalformed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(newServer().upload))
+	defer srv.Close()
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	mw.WriteField("field", "value")
+	mw.Close()
+
+	res, err := http.Post(srv.URL+"?fault=malformed", mw.FormDataContentType(), buf)
+	if err != nil {
+		t.Errorf("server did not return ok cause %v", err)
+		return
+	}
+	defer res.Body.Close()
+	mr := multipart.NewReader(res.Body, "mse6boundary")
+	for {
+		p, err := mr.NextPart()
+		if err == nil {
+			_, err = ioutil.ReadAll(p)
+		}
+		if err == io.EOF {
+			t.Errorf("want malformed multipart response")
+		}
+		if err != nil {
+			break
+		}
+	}
+}
+
+func TestUploadIncompleteBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/upload", iotest.TimeoutReader(strings.NewReader("partial upload")))
+	w := httptest.NewRecorder()
+	newServer().upload(w, req)
+	if w.Code != 400 {
+		t.Errorf("response status code want 400, got %v: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUploadResumableRejectsFailedChunks(t *testing.T) {
+	s := newServer()
+	data := bytes.Repeat([]byte("mse6"), 256)
+	put := func(id string, body io.Reader, cr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/upload?id="+id, body)
+		req.Header.Set("Content-Range", cr)
+		w := httptest.NewRecorder()
+		s.upload(w, req)
+		return w
+	}
+
+	if w := put("", bytes.NewReader(data), "bytes 0-1023/1024"); w.Code != 400 {
+		t.Errorf("without id status code want 400, got %v", w.Code)
+	}
+	if w := put("c", bytes.NewReader(data[:512]), "bytes 0-511/1024"); w.Code != 308 {
+		t.Errorf("first chunk status code want 308, got %v", w.Code)
+	}
+	if w := put("c", bytes.NewReader(data[512:600]), "bytes 512-1023/1024"); w.Code != 400 {
+		t.Errorf("short chunk status code want 400, got %v", w.Code)
+	}
+	if w := put("c", iotest.TimeoutReader(iotest.OneByteReader(bytes.NewReader(data[512:]))), "bytes 512-1023/1024"); w.Code != 400 {
+		t.Errorf("failed chunk status code want 400, got %v", w.Code)
+	}
+	if w := put("c", bytes.NewReader(nil), "bytes */1024"); w.Header().Get("Range") != "bytes=0-511" {
+		t.Errorf("want session unchanged after rejected chunks, got Range %v", w.Header().Get("Range"))
+	}
+	w := put("c", bytes.NewReader(data[512:]), "bytes 512-1023/1024")
+	want := sha256.Sum256(data)
+	if w.Code != 200 || !strings.Contains(w.Body.String(), hex.EncodeToString(want[:])) {
+		t.Errorf("want completed upload with sha256 of all chunks, got %v %s", w.Code, w.Body.String())
+	}
+}
+
+func TestUploadStoreEvictsIdleSessions(t *testing.T) {
+	s := newStateStore().uploads
+	now := time.Now()
+
+	s.session("idle", now)
+	s.session("busy", now.Add(uploadSessionTimeout-time.Minute))
+	s.session("new", now.Add(uploadSessionTimeout))
+
+	if _, ok := s.sessions["idle"]; ok {
+		t.Errorf("want idle session evicted")
+	}
+	if len(s.sessions) != 2 {
+		t.Errorf("want 2 sessions, got %d", len(s.sessions))
+	}
+}
@@ -0,0 +1,57 @@
+package mse6
+
+import (
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"sync"
+)
+
+type zstdCodec struct {
+	encPool sync.Pool
+	decPool sync.Pool
+}
+
+func newZstdCodec() *zstdCodec {
+	return &zstdCodec{
+		encPool: sync.Pool{
+			New: func() interface{} {
+				w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedFastest))
+				return w
+			},
+		},
+		decPool: sync.Pool{
+			New: func() interface{} {
+				r, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+				return r
+			},
+		},
+	}
+}
+
+func (c *zstdCodec) Name() string {
+	return "zstd"
+}
+
+func (c *zstdCodec) Encode(w io.Writer) (io.WriteCloser, error) {
+	return newPooledWriter(&c.encPool, w), nil
+}
+
+func (c *zstdCodec) Decode(r io.Reader) (io.ReadCloser, error) {
+	rd := c.decPool.Get().(*zstd.Decoder)
+	if err := rd.Reset(r); err != nil {
+		rd.Close()
+		return nil, err
+	}
+	//decoders that are not returned to the pool must be closed to stop their goroutines.
+	return &pooledReader{dec: rd, item: rd, pool: &c.decPool, discard: rd.Close}, nil
+}
+
+// ZstdEncode encodes to zstd from byte array.
+func ZstdEncode(input []byte) ([]byte, error) {
+	return encodeBytes("zstd", input)
+}
+
+// ZstdDecode decodes a []byte from zstd binary format
+func ZstdDecode(input []byte) ([]byte, error) {
+	return decodeBytes("zstd", input)
+}
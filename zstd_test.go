@@ -0,0 +1,13 @@
+package mse6
+
+import "testing"
+
+func TestZstdEncodeAndDecode(t *testing.T) {
+	want := "ResistanceIsFutile"
+	enc, _ := ZstdEncode([]byte(want))
+	got, err := ZstdDecode(enc)
+
+	if err != nil || string(got) != want {
+		t.Errorf("zstd decode/encode failed. want %v got %v cause %v", want, string(got), err)
+	}
+}